@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Mobility holds config for residential moves
+type Mobility struct {
+	MovingRates []*AgeRate `json:"moving_rates"`
+}
+
+// AgeRate is an annual rate for people aged MinAge to MaxAge years
+type AgeRate struct {
+	MinAge int     `json:"min_age"`
+	MaxAge int     `json:"max_age"`
+	Rate   float64 `json:"rate"`
+}
+
+// address is a person's place of residence from start up to, but not including, end
+type address struct {
+	geoCode string
+	start   int64
+	end     int64
+}
+
+func (m *Mobility) validate() error {
+	return validateAgeRates("moving rate", m.MovingRates)
+}
+
+// validateAgeRates checks that rates have valid age ranges and are probabilities
+func validateAgeRates(name string, rates []*AgeRate) error {
+	for _, r := range rates {
+		if r.MinAge < 0 || r.MaxAge < r.MinAge {
+			return fmt.Errorf("invalid %s age range %d-%d", name, r.MinAge, r.MaxAge)
+		}
+		if r.Rate < 0 || r.Rate > 1 {
+			return fmt.Errorf("%s for ages %d-%d must be between 0 and 1", name, r.MinAge, r.MaxAge)
+		}
+	}
+	return nil
+}
+
+// rateAt returns the rate for age, 0 if no rate covers age
+func rateAt(rates []*AgeRate, age int) float64 {
+	for _, r := range rates {
+		if age >= r.MinAge && age <= r.MaxAge {
+			return r.Rate
+		}
+	}
+	return 0
+}
+
+// addAddresses generates the person's address history during coverage. In each year of coverage,
+// the person moves to a new random geocode with the moving rate for their age at the start of the year.
+// The person's geoCode is set to the last address.
+func (p *Person) addAddresses() {
+	lookup := p.config.Locator.lookup
+	current := address{geoCode: lookup.RandCode(p.rnd), start: p.regisDate}
+	if p.config.Mobility != nil {
+		for t := p.regisDate; t < p.cancelDate; t += secondsInDay * daysInYear {
+			if p.rnd.Float64() >= rateAt(p.config.Mobility.MovingRates, ageAt(p.dob, t)) {
+				continue
+			}
+			end := t + secondsInDay*daysInYear
+			if end > p.cancelDate {
+				end = p.cancelDate
+			}
+			moveDate := RangeDate(p.rnd, t, end)
+			if moveDate <= current.start {
+				continue
+			}
+			geoCode := lookup.RandCode(p.rnd)
+			for i := 0; i < 10 && geoCode == current.geoCode && len(lookup.Codes) > 1; i++ {
+				geoCode = lookup.RandCode(p.rnd)
+			}
+			current.end = moveDate
+			p.addresses = append(p.addresses, current)
+			current = address{geoCode: geoCode, start: moveDate}
+		}
+	}
+	current.end = p.cancelDate
+	p.addresses = append(p.addresses, current)
+	p.geoCode = current.geoCode
+}
+
+// clipAddresses returns the part of an address history between start and end; the last address
+// is extended to end if needed
+func clipAddresses(addresses []address, start, end int64) []address {
+	var clipped []address
+	for _, a := range addresses {
+		if a.end <= start || a.start >= end {
+			continue
+		}
+		if a.start < start {
+			a.start = start
+		}
+		if a.end > end {
+			a.end = end
+		}
+		clipped = append(clipped, a)
+	}
+	if n := len(clipped); n > 0 && clipped[n-1].end < end {
+		clipped[n-1].end = end
+	}
+	return clipped
+}
+
+// geoCodeAt returns the geocode of the address where the person lived on date
+func (p *Person) geoCodeAt(date int64) string {
+	for _, a := range p.addresses {
+		if date < a.end {
+			return a.geoCode
+		}
+	}
+	return p.geoCode
+}
+
+func (a *address) toStrings(id int64) []string {
+	return []string{
+		strconv.Itoa(int(id)),
+		a.geoCode,
+		toTime(a.start).Format(dateLayoutISO),
+		toTime(a.end).Format(dateLayoutISO),
+	}
+}
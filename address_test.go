@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	locator, err := newLookup("postal_code", []string{"A", "B", "C"}, []float64{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := int64(secondsInDay)
+	config := &Config{
+		Locator:  &LookupDescriptor{lookup: locator},
+		Mobility: &Mobility{MovingRates: []*AgeRate{{MinAge: 0, MaxAge: 200, Rate: 1}}},
+	}
+	p := &Person{config: config, dob: 0, regisDate: 10 * daysInYear * day, cancelDate: 20 * daysInYear * day, rnd: rand.New(rand.NewSource(1))}
+	p.addAddresses()
+	if len(p.addresses) < 2 {
+		t.Fatalf("got %d addresses, want a move in most years", len(p.addresses))
+	}
+	start := p.regisDate
+	for i, a := range p.addresses {
+		if a.start != start || a.end <= a.start {
+			t.Fatalf("address %d = [%d, %d], want to start at %d", i, a.start, a.end, start)
+		}
+		if i > 0 && a.geoCode == p.addresses[i-1].geoCode {
+			t.Errorf("address %d does not change geocode", i)
+		}
+		if got := p.geoCodeAt(a.start); got != a.geoCode {
+			t.Errorf("geoCodeAt(start of address %d) = %s, want %s", i, got, a.geoCode)
+		}
+		start = a.end
+	}
+	if start != p.cancelDate || p.geoCode != p.addresses[len(p.addresses)-1].geoCode {
+		t.Errorf("last address must end at coverage end and be the person's geocode")
+	}
+	config.Mobility = nil
+	p.addresses = nil
+	p.addAddresses()
+	if len(p.addresses) != 1 {
+		t.Errorf("got %d addresses without mobility, want 1", len(p.addresses))
+	}
+}
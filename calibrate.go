@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drgo/sim/identify"
+)
+
+// calibrationTargets holds the statistics that a calibrated configuration should reproduce
+type calibrationTargets struct {
+	Diseases []*diseaseTargets `json:"diseases"`
+}
+
+// diseaseTargets holds the target statistics of a disease; missing targets are not calibrated.
+// Prevalence is the proportion of the study population found by the case definition of the disease.
+// Rates are the records with the disease's code (icd10, icd9 or a DIN of its dins or drug classes)
+// per year of follow-up from onset of the people with the disease. StayLength is the mean length in
+// days of the hospital stays with the disease's icd10 code.
+type diseaseTargets struct {
+	Name         string   `json:"name"`
+	Prevalence   *float64 `json:"prevalence"`
+	HospitalRate *float64 `json:"hospital_rate"`
+	ClinicRate   *float64 `json:"clinic_rate"`
+	RxRate       *float64 `json:"rx_rate"`
+	StayLength   *float64 `json:"stay_length"`
+}
+
+// calibration searches the parameters of a configuration document for values that reproduce
+// target statistics in simulations of N people
+type calibration struct {
+	doc        map[string]interface{}
+	N          int
+	Iterations int
+	Tolerance  float64 // relative
+	defs       *identify.Definitions
+	study      identify.Study
+	params     []*calibrationParam
+	out        io.Writer
+}
+
+// calibrationParam is a parameter of the configuration calibrated to reproduce a statistic of a disease
+type calibrationParam struct {
+	disease string
+	stat    string // name of the statistic, eg hospital_rate
+	target  float64
+	get     func() float64
+	set     func(v float64)
+	max     float64            // largest value, if > 0
+	history []calibrationPoint // values tried
+}
+
+type calibrationPoint struct {
+	value, observed float64
+}
+
+// loadCalibrationTargets reads a json file of calibration targets
+func loadCalibrationTargets(fileName string) (*calibrationTargets, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	targets := &calibrationTargets{}
+	if err = decoder.Decode(targets); err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, jsonError(data, err))
+	}
+	if len(targets.Diseases) == 0 {
+		return nil, fmt.Errorf("%s: no disease targets", fileName)
+	}
+	for _, t := range targets.Diseases {
+		for name, v := range map[string]*float64{"prevalence": t.Prevalence, "hospital_rate": t.HospitalRate,
+			"clinic_rate": t.ClinicRate, "rx_rate": t.RxRate, "stay_length": t.StayLength} {
+			if v != nil && !(*v > 0) || name == "prevalence" && v != nil && *v >= 1 {
+				return nil, fmt.Errorf("%s: disease %s: %s must be > 0 (and < 1 for a prevalence), got %v", fileName, t.Name, name, *v)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// newCalibration returns a calibration of the configuration in configFile to targets
+func newCalibration(configFile string, targets *calibrationTargets, defs *identify.Definitions) (*calibration, error) {
+	data, err := readConfigDocument(configFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &calibration{defs: defs, out: os.Stdout}
+	if err = json.Unmarshal(data, &c.doc); err != nil {
+		return nil, err
+	}
+	diseases, _ := c.doc["diseases"].([]interface{})
+	for _, t := range targets.Diseases {
+		var disease map[string]interface{}
+		for _, d := range diseases {
+			if d, ok := d.(map[string]interface{}); ok && d["name"] == t.Name {
+				disease = d
+			}
+		}
+		if disease == nil {
+			return nil, fmt.Errorf("calibration targets: unknown disease %q", t.Name)
+		}
+		if err = c.addParams(disease, t); err != nil {
+			return nil, fmt.Errorf("disease %s: %s", t.Name, err)
+		}
+	}
+	if len(c.params) == 0 {
+		return nil, fmt.Errorf("calibration targets: no target statistics")
+	}
+	return c, nil
+}
+
+// addParams adds the parameters of disease calibrated to its targets
+func (c *calibration) addParams(disease map[string]interface{}, t *diseaseTargets) error {
+	if t.Prevalence != nil {
+		if c.defs == nil {
+			return fmt.Errorf("a prevalence target requires case definitions")
+		}
+		male, _ := disease["prevalence_male"].(float64)
+		female, _ := disease["prevalence_female"].(float64)
+		if male <= 0 && female <= 0 {
+			return fmt.Errorf("prevalence_male or prevalence_female must be > 0 to calibrate prevalence")
+		}
+		// both prevalences are multiplied by a factor, keeping their ratio
+		factor := 1.0
+		c.params = append(c.params, &calibrationParam{
+			disease: t.Name, stat: "prevalence", target: *t.Prevalence,
+			get: func() float64 { return factor },
+			set: func(v float64) {
+				factor = v
+				disease["prevalence_male"] = male * v
+				disease["prevalence_female"] = female * v
+			},
+			max: 1 / math.Max(male, female),
+		})
+	}
+	for _, rate := range []struct {
+		field  string
+		target *float64
+	}{
+		{"hospital_rate", t.HospitalRate},
+		{"clinic_rate", t.ClinicRate},
+		{"rx_rate", t.RxRate},
+	} {
+		if rate.target == nil {
+			continue
+		}
+		param, err := meanParam(disease, rate.field)
+		if err != nil {
+			return err
+		}
+		param.disease, param.target = t.Name, *rate.target
+		c.params = append(c.params, param)
+	}
+	if t.StayLength != nil {
+		// stay_length is calibrated in a hospitalization entry of the disease
+		if _, found := disease["hospitalization"]; !found {
+			data, err := json.Marshal(c.doc["hospitalization"])
+			if err != nil {
+				return err
+			}
+			var h map[string]interface{}
+			if err = json.Unmarshal(data, &h); err != nil || h == nil {
+				return fmt.Errorf("a stay_length target requires a hospitalization entry")
+			}
+			disease["hospitalization"] = h
+		}
+		h, ok := disease["hospitalization"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("hospitalization must be an object")
+		}
+		param, err := meanParam(h, "stay_length")
+		if err != nil {
+			return err
+		}
+		param.disease, param.target = t.Name, *t.StayLength
+		c.params = append(c.params, param)
+	}
+	return nil
+}
+
+// meanParam returns the parameter setting the mean of the distribution in obj[field]: the Mean
+// of the {Mean, SD} form, the mean parameter of a distribution or the lambda of a poisson
+func meanParam(obj map[string]interface{}, field string) (*calibrationParam, error) {
+	dist, ok := obj[field].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is required to calibrate it", field)
+	}
+	key := ""
+	for k := range dist {
+		if _, isDist := dist["dist"]; isDist && (k == "mean" || k == "lambda") || !isDist && strings.EqualFold(k, "mean") {
+			key = k
+		}
+	}
+	if key == "" {
+		return nil, fmt.Errorf("cannot calibrate %s: it must be given as {Mean, SD}, with a mean parameter or as a poisson lambda", field)
+	}
+	return &calibrationParam{
+		stat: field,
+		get: func() float64 {
+			v, _ := dist[key].(float64)
+			return v
+		},
+		set: func(v float64) { dist[key] = v },
+	}, nil
+}
+
+// search calibrates the parameters and returns the largest relative difference between the target
+// and observed statistics of the best values found, which are set in the document
+func (c *calibration) search() (float64, error) {
+	dir, err := ioutil.TempDir("", "sim-calibrate")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+	const row = "%9v  %-20.20s  %-13s  %9.4g  %9.4g  %9.4g\n"
+	fmt.Fprintf(c.out, strings.Replace(row, ".4g", "s", -1), "iteration", "disease", "statistic", "target", "observed", "parameter")
+	best, bestErr := make([]float64, len(c.params)), math.Inf(1)
+	for iteration := 1; ; iteration++ {
+		observed, err := c.simulate(dir)
+		if err != nil {
+			return 0, err
+		}
+		maxErr := 0.0
+		for _, p := range c.params {
+			y := observed[p.disease][p.stat]
+			p.history = append(p.history, calibrationPoint{p.get(), y})
+			maxErr = math.Max(maxErr, math.Abs(y-p.target)/p.target)
+			fmt.Fprintf(c.out, row, iteration, p.disease, p.stat, p.target, y, p.get())
+		}
+		if maxErr < bestErr {
+			bestErr = maxErr
+			for i, p := range c.params {
+				best[i] = p.get()
+			}
+		}
+		if maxErr <= c.Tolerance || iteration >= c.Iterations {
+			break
+		}
+		for _, p := range c.params {
+			p.set(p.next())
+		}
+	}
+	for i, p := range c.params {
+		p.set(best[i])
+	}
+	return bestErr, nil
+}
+
+// next returns the next value of the parameter: a secant step through the last two values tried
+// or, when the secant cannot be used, the last value scaled by target/observed. Steps are limited
+// to a factor of 4 and values are rounded to 4 significant digits.
+func (p *calibrationParam) next() float64 {
+	n := len(p.history)
+	last := p.history[n-1]
+	if last.value <= 0 {
+		return p.target
+	}
+	next := 0.0
+	if n > 1 {
+		prev := p.history[n-2]
+		if slope := (last.observed - prev.observed) / (last.value - prev.value); slope > 0 && !math.IsInf(slope, 0) {
+			next = last.value + (p.target-last.observed)/slope
+		}
+	}
+	if next <= 0 {
+		if last.observed > 0 {
+			next = last.value * p.target / last.observed
+		} else {
+			next = 2 * last.value
+		}
+	}
+	next = math.Max(last.value/4, math.Min(4*last.value, next))
+	if p.max > 0 && next > p.max {
+		next = p.max
+	}
+	next, _ = strconv.ParseFloat(strconv.FormatFloat(next, 'g', 4, 64), 64)
+	return next
+}
+
+// simulate generates data using the current parameters and returns the observed statistics by
+// disease name and statistic name. The same seed is used by every simulation, so that the
+// statistics change only with the parameters.
+func (c *calibration) simulate(dir string) (map[string]map[string]float64, error) {
+	data, err := json.Marshal(c.doc)
+	if err != nil {
+		return nil, err
+	}
+	config, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	config.N = c.N
+	config.Options.TruthNeeded = true
+	config.quiet = true
+	config.dispatcher = NewDispatcher(bufferSize, config)
+	if config, err = ProcessConfig(config); err != nil {
+		return nil, err
+	}
+	codes, err := c.diseaseCodes(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = run(config, dir); err != nil {
+		return nil, err
+	}
+	return c.measure(config, codes, dir)
+}
+
+// calibratedTables are the tables from which the statistics other than prevalence are measured
+var calibratedTables = map[string]string{"hospital_rate": "hosp", "stay_length": "hosp", "clinic_rate": "clinic", "rx_rate": "rx"}
+
+// diseaseCodes returns the name of the disease generating each code by table: its icd10 code in
+// hosp, its icd9 code in clinic and its DINs and the DINs of its drug classes in rx. Records are
+// attributed to a disease by their code, so a code generated by two diseases cannot be used to
+// measure a calibrated statistic.
+func (c *calibration) diseaseCodes(config *Config) (map[string]map[string]string, error) {
+	calibrated := make(map[string]bool)
+	for _, p := range c.params {
+		if table, found := calibratedTables[p.stat]; found {
+			calibrated[table] = true
+		}
+	}
+	codes := map[string]map[string]string{"hosp": {}, "clinic": {}, "rx": {}}
+	add := func(table, code, disease string) error {
+		if other, found := codes[table][code]; found && other != disease && calibrated[table] {
+			return fmt.Errorf("diseases %s and %s both generate code %s in %s.csv, so its records cannot be attributed for calibration", other, disease, code, table)
+		}
+		codes[table][code] = disease
+		return nil
+	}
+	for _, disease := range config.Diseases {
+		if err := add("hosp", disease.Icd10, disease.Name); err != nil {
+			return nil, err
+		}
+		if err := add("clinic", disease.Icd9, disease.Name); err != nil {
+			return nil, err
+		}
+		dins := make([]string, 0, len(disease.Dins))
+		for _, din := range disease.Dins {
+			dins = append(dins, din.DIN)
+		}
+		for _, class := range disease.DrugClasses {
+			dins = append(dins, class.lookup.Codes...)
+		}
+		for _, din := range dins {
+			if err := add("rx", din, disease.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return codes, nil
+}
+
+// measure returns the statistics of the data generated in dir using config, attributing records to
+// diseases by the codes of diseaseCodes
+func (c *calibration) measure(config *Config, codes map[string]map[string]string, dir string) (map[string]map[string]float64, error) {
+	coverageEnd := make(map[string]time.Time)
+	err := readCSVFile(filepath.Join(dir, "person.csv"), func(rec map[string]string) error {
+		end, err := time.Parse(dateLayoutISO, rec["coverage_end"])
+		coverageEnd[rec["subject_id"]] = end
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// years of follow-up from onset by condition
+	years := make(map[string]float64)
+	err = readCSVFile(filepath.Join(dir, "truth.csv"), func(rec map[string]string) error {
+		if rec["status"] != "1" {
+			return nil
+		}
+		onset, err := time.Parse(dateLayoutISO, rec["onset_date"])
+		years[rec["disease"]] += coverageEnd[rec["subject_id"]].Sub(onset).Hours() / 24 / 365.25
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	type counts struct {
+		hosp, clinic, rx, stayDays int
+	}
+	byDisease := make(map[string]*counts, len(config.Diseases))
+	for _, disease := range config.Diseases {
+		byDisease[disease.Name] = &counts{}
+	}
+	for category, diseases := range codes {
+		err = readCSVFile(filepath.Join(dir, category+".csv"), func(rec map[string]string) error {
+			disease, found := diseases[rec["code"]]
+			if !found {
+				return nil
+			}
+			n := byDisease[disease]
+			switch category {
+			case "hosp":
+				n.hosp++
+				start, err := time.Parse(dateLayoutISO, rec["service_date"])
+				if err != nil {
+					return err
+				}
+				end, err := time.Parse(dateLayoutISO, rec["discharge_date"])
+				n.stayDays += int(end.Sub(start).Hours() / 24)
+				return err
+			case "clinic":
+				n.clinic++
+			default:
+				n.rx++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	cases, population, err := c.identify(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]map[string]float64, len(config.Diseases))
+	for _, disease := range config.Diseases {
+		n := byDisease[disease.Name]
+		y := years[disease.ConditionID]
+		stats[disease.Name] = map[string]float64{
+			"prevalence":    ratio(float64(cases[disease.ConditionID]), float64(population)),
+			"hospital_rate": ratio(float64(n.hosp), y),
+			"clinic_rate":   ratio(float64(n.clinic), y),
+			"rx_rate":       ratio(float64(n.rx), y),
+			"stay_length":   ratio(float64(n.stayDays), float64(n.hosp)),
+		}
+	}
+	return stats, nil
+}
+
+// identify returns the number of cases found by the case definitions by condition and the size of
+// the study population of the data generated in dir
+func (c *calibration) identify(dir string) (map[string]int, int, error) {
+	if c.defs == nil {
+		return nil, 0, nil
+	}
+	id := identify.New(c.defs, c.study)
+	file, err := os.Open(filepath.Join(dir, "person.csv"))
+	if err != nil {
+		return nil, 0, err
+	}
+	err = id.LoadPopulation(file)
+	file.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, src := range []struct{ source, fileName string }{
+		{identify.SourceHosp, "hosp.csv"},
+		{identify.SourcePhys, "clinic.csv"},
+		{identify.SourceDpin, "rx.csv"},
+	} {
+		file, err := os.Open(filepath.Join(dir, src.fileName))
+		if err != nil {
+			return nil, 0, err
+		}
+		err = id.AddSource(src.source, file)
+		file.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %s", src.fileName, err)
+		}
+	}
+	cases := make(map[string]int)
+	for _, found := range id.Cases() {
+		cases[found.ConditionID]++
+	}
+	return cases, len(id.Population()), nil
+}
+
+// readCSVFile calls fn with each record of a csv file by field name
+func readCSVFile(fileName string, fn func(rec map[string]string) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %s", fileName, err)
+	}
+	rec := make(map[string]string, len(header))
+	for line := 2; ; line++ {
+		values, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", fileName, err)
+		}
+		for i, name := range header {
+			rec[name] = values[i]
+		}
+		if err = fn(rec); err != nil {
+			return fmt.Errorf("%s: line %d: %s", fileName, line, err)
+		}
+	}
+}
+
+// ratio returns num/den or 0 if den is 0
+func ratio(num, den float64) float64 {
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestCalibrationParamNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []calibrationPoint
+		max     float64
+		want    float64
+	}{
+		{"ratio", []calibrationPoint{{2, 4}}, 0, 1.5},
+		{"secant", []calibrationPoint{{2, 4}, {1.5, 3.5}}, 0, 1},
+		{"decreasing", []calibrationPoint{{2, 4}, {1, 5}}, 0, 0.6},
+		{"limited step", []calibrationPoint{{1, 0.1}}, 0, 4},
+		{"max", []calibrationPoint{{1, 1}}, 2, 2},
+		{"none observed", []calibrationPoint{{1, 0}}, 0, 2},
+		{"rounded", []calibrationPoint{{1, 2.9}}, 0, 1.034},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &calibrationParam{target: 3, history: tt.history, max: tt.max}
+			if got := p.next(); got != tt.want {
+				t.Errorf("next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	rate, stay := 2.0, 5.0
+	targets := &calibrationTargets{Diseases: []*diseaseTargets{{Name: "diabetes", ClinicRate: &rate, StayLength: &stay}}}
+	c, err := newCalibration("./config.json", targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.N, c.Iterations, c.Tolerance, c.out = 500, 10, 0.05, ioutil.Discard
+	maxErr, err := c.search()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxErr > c.Tolerance {
+		t.Errorf("did not converge: %.3f off a target", maxErr)
+	}
+	diabetes := c.doc["diseases"].([]interface{})[0].(map[string]interface{})
+	mean := diabetes["clinic_rate"].(map[string]interface{})["Mean"].(float64)
+	if math.Abs(mean-6) < 1e-9 {
+		t.Errorf("clinic_rate Mean not calibrated")
+	}
+	if _, found := diabetes["hospitalization"]; !found {
+		t.Errorf("stay_length not calibrated in a hospitalization entry of the disease")
+	}
+
+	prevalence := 0.1
+	targets.Diseases[0].Prevalence = &prevalence
+	if _, err = newCalibration("./config.json", targets, nil); err == nil {
+		t.Errorf("a prevalence target without case definitions should be rejected")
+	}
+	targets.Diseases[0].Name = "flu"
+	if _, err = newCalibration("./config.json", targets, nil); err == nil {
+		t.Errorf("a target for an unknown disease should be rejected")
+	}
+}
+
+func TestDiseaseCodes(t *testing.T) {
+	config := &Config{Diseases: []*Disease{
+		{Name: "diabetes", Icd10: "E11", Icd9: "250", Dins: []DIN{{DIN: "1"}},
+			DrugClasses: []*DrugClass{{ATC: "A10%", lookup: &Lookup{Codes: []string{"2", "3"}}}}},
+		{Name: "copd", Icd10: "J44", Icd9: "496", Dins: []DIN{{DIN: "3"}}},
+	}}
+	c := &calibration{params: []*calibrationParam{{disease: "diabetes", stat: "clinic_rate"}}}
+	codes, err := c.diseaseCodes(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes["rx"]["2"] != "diabetes" || codes["clinic"]["496"] != "copd" {
+		t.Errorf("codes = %v, want the DINs of drug classes and the codes of every disease", codes)
+	}
+	c.params = append(c.params, &calibrationParam{disease: "copd", stat: "rx_rate"})
+	if _, err = c.diseaseCodes(config); err == nil {
+		t.Errorf("DIN 3 of both diseases should be rejected when rx_rate is calibrated")
+	}
+}
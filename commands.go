@@ -0,0 +1,355 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/drgo/sim/identify"
+)
+
+// commands holds sim sub-commands. Running sim without a command generates data using config.json.
+var commands = map[string]func(args []string) error{
+	"identify":       identifyCommand,
+	"validate":       validateCommand,
+	"check-config":   checkConfigCommand,
+	"generate":       generateCommand,
+	"calibrate":      calibrateCommand,
+	"migrate-config": migrateConfigCommand,
+}
+
+func runCommand(name string, args []string) error {
+	cmd, found := commands[name]
+	if !found {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command: %s. Available commands: %s", name, strings.Join(names, ", "))
+	}
+	return cmd(args)
+}
+
+// identifyCommand applies the identify-conditions case definitions to generated data
+// and writes conditions_long.csv, conditions_binary.csv and conditions_date.csv.
+func identifyCommand(args []string) error {
+	fs := flag.NewFlagSet("identify", flag.ExitOnError)
+	configDir := fs.String("config", "config", "folder containing conditions.csv, criteria.csv, patterns.csv and optionally rx_reference.csv")
+	dataDir := fs.String("data", ".", "folder containing the generated csv files")
+	outDir := fs.String("out", ".", "folder to write results to")
+	personFile := fs.String("person", "person.csv", "population file name")
+	hospFile := fs.String("hosp", "hosp.csv", "hospitalization (HOSP) file name")
+	clinicFile := fs.String("clinic", "clinic.csv", "physician claims (PHYS) file name")
+	rxFile := fs.String("rx", "rx.csv", "prescriptions (DPIN) file name")
+	start := fs.String("start", "", "study period start date (yyyy-mm-dd)")
+	end := fs.String("end", "", "study period end date (yyyy-mm-dd)")
+	minAge := fs.Int("min_age", 0, "minimum age for inclusion in the study period")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	study, err := parseStudy(*start, *end, *minAge)
+	if err != nil {
+		return err
+	}
+	defs, err := identify.LoadDefinitions(*configDir)
+	if err != nil {
+		return err
+	}
+	id := identify.New(defs, study)
+	file, err := os.Open(filepath.Join(*dataDir, *personFile))
+	if err != nil {
+		return err
+	}
+	err = id.LoadPopulation(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", *personFile, err)
+	}
+	for _, src := range []struct{ source, fileName string }{
+		{identify.SourceHosp, *hospFile},
+		{identify.SourcePhys, *clinicFile},
+		{identify.SourceDpin, *rxFile},
+	} {
+		file, err := os.Open(filepath.Join(*dataDir, src.fileName))
+		if os.IsNotExist(err) {
+			log.Printf("skipping %s data source: %s", src.source, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = id.AddSource(src.source, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", src.fileName, err)
+		}
+	}
+	cases := id.Cases()
+	population := id.Population()
+	outputs := []struct {
+		fileName string
+		write    func(f *os.File) error
+	}{
+		{"conditions_long.csv", func(f *os.File) error { return identify.WriteLong(f, cases) }},
+		{"conditions_binary.csv", func(f *os.File) error { return identify.WriteBinary(f, population, defs.Conditions, cases) }},
+		{"conditions_date.csv", func(f *os.File) error { return identify.WriteDate(f, population, defs.Conditions, cases) }},
+	}
+	for _, out := range outputs {
+		f, err := os.Create(filepath.Join(*outDir, out.fileName))
+		if err != nil {
+			return err
+		}
+		if err = out.write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		fmt.Println("done writing to:", out.fileName)
+	}
+	return nil
+}
+
+// parseStudy returns the study restrictions of the identify and calibrate commands
+func parseStudy(start, end string, minAge int) (identify.Study, error) {
+	var (
+		study = identify.Study{MinAge: minAge}
+		err   error
+	)
+	if start != "" {
+		if study.Start, err = time.Parse(dateLayoutISO, start); err != nil {
+			return study, fmt.Errorf("invalid study start date: %s", err)
+		}
+	}
+	if end != "" {
+		if study.End, err = time.Parse(dateLayoutISO, end); err != nil {
+			return study, fmt.Errorf("invalid study end date: %s", err)
+		}
+	}
+	return study, nil
+}
+
+// validateCommand compares the cases found by a case definition to truth.csv and reports
+// the accuracy of the case definition by condition.
+func validateCommand(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	truthFile := fs.String("truth", "truth.csv", "truth file generated using the truth_needed option")
+	casesFile := fs.String("cases", "conditions_long.csv", "cases found by the case definition in long format")
+	populationFile := fs.String("population", "", "optional file with a subject_id field, eg conditions_binary.csv, restricting the comparison to the study population")
+	outFile := fs.String("out", "", "optional csv file to write results to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	file, err := os.Open(*truthFile)
+	if err != nil {
+		return err
+	}
+	truth, err := identify.ReadTruth(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", *truthFile, err)
+	}
+	if file, err = os.Open(*casesFile); err != nil {
+		return err
+	}
+	cases, err := identify.ReadLong(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", *casesFile, err)
+	}
+	var population []string
+	if *populationFile != "" {
+		if file, err = os.Open(*populationFile); err != nil {
+			return err
+		}
+		population, err = identify.ReadSubjects(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", *populationFile, err)
+		}
+	}
+	results := identify.Validate(truth, cases, population)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "condition\tTP\tFP\tFN\tTN\tsens\tspec\tPPV\tNPV\tkappa\tonset error (days) mean\tmedian\tmean abs\t")
+	for _, a := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.1f\t%.1f\t%.1f\t\n", a.ConditionID, a.TP, a.FP, a.FN, a.TN,
+			a.Sensitivity, a.Specificity, a.PPV, a.NPV, a.Kappa, a.MeanOnsetError, a.MedianOnsetError, a.MeanAbsOnsetError)
+	}
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return nil
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		return err
+	}
+	if err = identify.WriteAccuracy(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// generateCommand generates data using a configuration file other than the default.
+func generateCommand(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	configFile := fs.String("config", defaultConfigFile(), "configuration file (json, yaml or toml)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	generate(*configFile)
+	return nil
+}
+
+// calibrateCommand searches the disease parameters of a configuration for values that reproduce
+// target statistics in small simulations and writes the calibrated configuration.
+func calibrateCommand(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	configFile := fs.String("config", defaultConfigFile(), "configuration file (json, yaml or toml) to calibrate")
+	targetsFile := fs.String("targets", "targets.json", "json file of target statistics by disease")
+	defsDir := fs.String("definitions", "", "folder containing the identify case definitions; required by prevalence targets")
+	outFile := fs.String("out", "calibrated-config.json", "file to write the calibrated configuration to (json, yaml or toml)")
+	n := fs.Int("n", 2000, "number of people in each simulation")
+	iterations := fs.Int("iterations", 10, "maximum number of simulations")
+	tolerance := fs.Float64("tolerance", 0.05, "largest acceptable relative difference between target and observed statistics")
+	start := fs.String("start", "", "study period start date (yyyy-mm-dd) of the case definitions")
+	end := fs.String("end", "", "study period end date (yyyy-mm-dd) of the case definitions")
+	minAge := fs.Int("min_age", 0, "minimum age for inclusion in the study period of the case definitions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *n < 1 || *iterations < 1 || *tolerance < 0 {
+		return fmt.Errorf("n and iterations must be > 0 and tolerance must not be negative")
+	}
+	targets, err := loadCalibrationTargets(*targetsFile)
+	if err != nil {
+		return err
+	}
+	var defs *identify.Definitions
+	if *defsDir != "" {
+		if defs, err = identify.LoadDefinitions(*defsDir); err != nil {
+			return err
+		}
+	}
+	c, err := newCalibration(*configFile, targets, defs)
+	if err != nil {
+		return fmt.Errorf("%s: %s", *configFile, err)
+	}
+	if c.study, err = parseStudy(*start, *end, *minAge); err != nil {
+		return err
+	}
+	c.N, c.Iterations, c.Tolerance = *n, *iterations, *tolerance
+	maxErr, err := c.search()
+	if err != nil {
+		return err
+	}
+	if err = writeDocument(*outFile, c.doc); err != nil {
+		return err
+	}
+	if maxErr > *tolerance {
+		fmt.Printf("did not converge: the best parameters found are %.1f%% off a target\n", 100*maxErr)
+	}
+	fmt.Printf("calibrated configuration written to %s\n", *outFile)
+	return nil
+}
+
+// checkConfigCommand checks a configuration file and the files it references without generating data.
+// Every problem found in the configuration is reported with its JSON path.
+func checkConfigCommand(args []string) error {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	configFile := fs.String("config", defaultConfigFile(), "configuration file (json, yaml or toml) to check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := LoadConfig(*configFile)
+	if cerr, ok := err.(*ConfigError); ok {
+		for _, problem := range cerr.Problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("%s: %d problem(s) found", *configFile, len(cerr.Problems))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", *configFile, err)
+	}
+	warnings := ""
+	if n := len(config.warnings); n > 0 {
+		warnings = fmt.Sprintf(" with %d warning(s)", n)
+	}
+	fmt.Printf("%s: ok%s. %d people, %d disease(s); writes %s\n", *configFile, warnings, config.N, len(config.Diseases),
+		strings.Join(config.outputs, ".csv, ")+".csv")
+	return nil
+}
+
+// migrateConfigCommand rewrites a configuration file written for an older version of sim, and the
+// files it includes, in the current format.
+func migrateConfigCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-config", flag.ExitOnError)
+	configFile := fs.String("config", defaultConfigFile(), "configuration file (json, yaml or toml) to migrate")
+	outFile := fs.String("out", "", "file to write the migrated configuration to; by default, the configuration file is rewritten and the original is kept with a .bak extension")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files, err := migrateFiles(*configFile, nil, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	// YAML and TOML files may hold comments, which are not kept when the document is written
+	hasComments := func(fileName string) bool { return strings.ToLower(filepath.Ext(fileName)) != ".json" }
+	// included files are rewritten in place, as other configurations may include them too;
+	// -out names only the file of the configuration
+	var included []*configDocument
+	for _, f := range files[1:] {
+		if f.from == configVersion {
+			continue
+		}
+		if *outFile != "" {
+			return fmt.Errorf("%s: included file %s is version %s; migrate it first with sim migrate-config -config %s", *configFile, f.name, f.from, f.name)
+		}
+		if hasComments(f.name) {
+			return fmt.Errorf("%s: included file %s is version %s and rewriting it would drop its comments; migrate it with sim migrate-config -config %s -out and include the migrated file", *configFile, f.name, f.from, f.name)
+		}
+		included = append(included, f)
+	}
+	config := files[0]
+	if *outFile == "" {
+		if config.from == configVersion && len(included) == 0 {
+			fmt.Printf("%s: already at version %s\n", *configFile, configVersion)
+			return nil
+		}
+		if config.from != configVersion && hasComments(*configFile) {
+			return fmt.Errorf("%s: rewriting the file would drop its comments; use -out to write the migrated configuration to another file", *configFile)
+		}
+	}
+	for _, f := range included {
+		if err = rewriteDocument(f.name, f.doc); err != nil {
+			return err
+		}
+		fmt.Printf("%s: included file migrated from version %s to %s\n", f.name, f.from, configVersion)
+	}
+	if *outFile == "" {
+		if config.from == configVersion {
+			return nil
+		}
+		if err = rewriteDocument(*configFile, config.doc); err != nil {
+			return err
+		}
+		*outFile = *configFile
+	} else if err = writeDocument(*outFile, config.doc); err != nil {
+		return err
+	}
+	if hasComments(*configFile) {
+		fmt.Printf("%s: comments are not copied to %s\n", *configFile, *outFile)
+	}
+	fmt.Printf("%s: migrated from version %s to %s and written to %s\n", *configFile, config.from, configVersion, *outFile)
+	return nil
+}
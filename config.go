@@ -1,95 +1,263 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
+	"log"
+	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/drgo/sim/rng"
+	"github.com/drgo/sim/simula"
 )
 
 // Config holds info on run config
 type Config struct {
-	Version         string            `json:"version"`
-	Seed            int               `json:"seed"`
-	N               int               `json:"n"`
-	Diseases        []*Disease        `json:"diseases"`
-	Population      *Population       `json:"population"`
-	Hospitalization *Hospitalization  `json:"hospitalization"`
-	Locator         *LookupDescriptor `json:"locator"`
+	Version         string                   `json:"version"`
+	Seed            int                      `json:"seed"`
+	N               int                      `json:"n"`
+	Diseases        []*Disease               `json:"diseases"`
+	Population      *Population              `json:"population"`
+	Hospitalization *Hospitalization         `json:"hospitalization"`
+	Locator         *LookupDescriptor        `json:"locator"`
+	RxReference     *DrugReferenceDescriptor `json:"rx_reference"`
+	Providers       *Providers               `json:"providers"`
+	Mobility        *Mobility                `json:"mobility"`
+	Households      *Households              `json:"households"`
+	Demography      *Demography              `json:"demography"`
+	SubjectIDs      *SubjectIDs              `json:"subject_ids"`
 	Options         struct {
-		LocationNeeded     bool `json:"location_needed"`
-		HospLocationNeeded bool `json:"hospital_location_needed"`
+		LocationNeeded          bool `json:"location_needed"`
+		HospLocationNeeded      bool `json:"hospital_location_needed"`
+		ATCNeeded               bool `json:"atc_needed"`
+		TruthNeeded             bool `json:"truth_needed"`
+		ProviderNeeded          bool `json:"provider_needed"`
+		AddressHistoryNeeded    bool `json:"address_history_needed"`
+		EncounterLocationNeeded bool `json:"encounter_location_needed"`
+		FamilyNeeded            bool `json:"family_needed"`
 	} `json:"options"`
+	Doc        []string          `json:"__doc"` // ignored
 	fieldNames map[string]string //tracks fieldnames for each csv file
+	outputs    []string          //csv files to write
 	dispatcher *Dispatcher
+	quiet      bool       // no progress messages or run report, eg during calibration
+	report     *runReport // nil if quiet
+	warnings   []string   // values that are ignored, by path
 }
 
 // Disease holds config for disease
 type Disease struct {
-	Name             string           `json:"name"`
-	PrevalenceMale   float64          `json:"prevalence_male"`
-	PrevalenceFemale float64          `json:"prevalence_female"`
-	Recurrence       int              `json:"recurrence"`
-	HospitalRate     Stats            `json:"hospital_rate"`
-	ClinicRate       Stats            `json:"clinic_rate"`
-	Icd9             string           `json:"icd9"`
-	Icd10            string           `json:"icd10"`
-	RxRate           Stats            `json:"rx_rate"`
-	Dins             []DIN            `json:"dins"`
-	Hospitalization  *Hospitalization `json:"hospitalization"`
+	Name             string             `json:"name"`
+	ConditionID      string             `json:"condition_id"`
+	PrevalenceMale   float64            `json:"prevalence_male"`
+	PrevalenceFemale float64            `json:"prevalence_female"`
+	Chronic          bool               `json:"chronic"`    // not implemented; a warning if set
+	Recurrence       int                `json:"recurrence"` // not implemented; a warning if set
+	HospitalRate     Dist               `json:"hospital_rate"`
+	ClinicRate       Dist               `json:"clinic_rate"`
+	Icd9             string             `json:"icd9"`
+	Icd10            string             `json:"icd10"`
+	RxRate           Dist               `json:"rx_rate"`
+	Dins             []DIN              `json:"dins"`
+	IndependentDins  bool               `json:"independent_dins"` // fill each DIN with probability Prob, as in version 1.0
+	DrugClasses      []*DrugClass       `json:"drug_classes"`
+	PrevalenceRatios []*PrevalenceRatio `json:"prevalence_ratios"`
+	Specialty        string             `json:"specialty"`
+	ReferralProb     float64            `json:"referral_prob"`
+	FamilialRatio    float64            `json:"familial_ratio"`
+	HouseholdRatio   float64            `json:"household_ratio"`
+	Hospitalization  *Hospitalization   `json:"hospitalization"`
+	dins             rng.Sampler        // samples Dins by Prob
+	cases            [2]int64           // people with the disease by sex, for the run report
 }
 
 type Population struct {
 	MigrantProb       float64 `json:"migrant_prob"`
 	CancelProb        float64 `json:"cancel_prob"`
 	DatabaseStartDate string  `json:"database_start_date"`
+	DatabaseEndDate   string  `json:"database_end_date"` // default: the date of the run
 	EarliestBirthDate string  `json:"earliest_birth_date"`
+	PyramidFileName   string  `json:"pyramid_csv_filename"`
+	PyramidYear       int     `json:"pyramid_year"`
 	minDate           int64
 	databaseStartDate int64
+	databaseEndDate   int64
+	ageSexGroups      []*AgeSexGroup
+	pyramid           *pyramid
+	pyramidDate       int64
 }
 
 type Hospitalization struct {
-	StayLength Stats             `json:"stay_length"`
-	Locator    *LookupDescriptor `json:"locator"`
+	StayLength         Dist              `json:"stay_length"`
+	StayDistribution   string            `json:"stay_distribution"`
+	Locator            *LookupDescriptor `json:"locator"`
+	Catchment          string            `json:"catchment_csv_filename"`
+	CatchmentAttribute string            `json:"catchment_attribute"`
+	AdultAge           int               `json:"adult_age"`
+	ReturnProb         float64           `json:"return_prob"`
+	chooser            *hospitalChooser
+}
+
+// stayDistributions maps stay_distribution values to the distributions of length of stay with the
+// mean and sd of a stay_length given as {Mean, SD}; normal distributions need no conversion
+var stayDistributions = map[string]func(mean, sd float64) (simula.Distribution, error){
+	"":       nil,
+	"normal": nil,
+	"lognormal": func(mean, sd float64) (simula.Distribution, error) {
+		return simula.LogNormalFromMoments(mean, sd)
+	},
+	"gamma": func(mean, sd float64) (simula.Distribution, error) {
+		return simula.GammaFromMoments(mean, sd)
+	},
+	"weibull": func(mean, sd float64) (simula.Distribution, error) {
+		return simula.WeibullFromMoments(mean, sd)
+	},
 }
 
+// Stats holds the mean and SD of a normal distribution, the older form of a Dist
 type Stats struct {
 	Mean float64
 	SD   float64
 }
 
+// Dist is a distribution configured as {"dist":"gamma","shape":2,"scale":3}, optionally truncated
+// by min and max (see the simula package), or in the older form {"Mean":m,"SD":s} of a normal
+// distribution. A missing Dist always returns 0.
+type Dist struct {
+	simula.Dist
+	stats *Stats // set by the older form
+}
+
+// statsDist returns the normal distribution of the older form
+func statsDist(s Stats) Dist {
+	return Dist{Dist: simula.Dist{Distribution: &simula.Normal{Mu: s.Mean, Sigma: s.SD}}, stats: &s}
+}
+
+// Rand returns a random value drawn using the random numbers of rnd
+func (d *Dist) Rand(rnd *rand.Rand) float64 {
+	if d.Distribution == nil {
+		return 0
+	}
+	return d.Distribution.Rand(rnd)
+}
+
+func (d *Dist) UnmarshalJSON(data []byte) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return fmt.Errorf("must be a distribution such as {\"dist\":\"gamma\",\"shape\":2,\"scale\":3} or {\"Mean\":2,\"SD\":1}")
+	}
+	if _, found := fields["dist"]; found {
+		d.stats = nil
+		return d.Dist.UnmarshalJSON(data)
+	}
+	var s Stats
+	for key, value := range fields {
+		v, ok := value.(float64)
+		switch {
+		case !strings.EqualFold(key, "mean") && !strings.EqualFold(key, "sd"):
+			return fmt.Errorf("unknown field %q; use {\"Mean\":m,\"SD\":s} or a distribution with a dist field", key)
+		case !ok:
+			return fmt.Errorf("%s must be a number, got %s", key, jsonType(value))
+		case strings.EqualFold(key, "mean"):
+			s.Mean = v
+		default:
+			s.SD = v
+		}
+	}
+	*d = statsDist(s)
+	return nil
+}
+
+// MarshalJSON writes the distribution in the form it was read
+func (d Dist) MarshalJSON() ([]byte, error) {
+	if d.Name == "" {
+		var s Stats
+		if d.stats != nil {
+			s = *d.stats
+		}
+		return json.Marshal(s)
+	}
+	return d.Dist.MarshalJSON()
+}
+
+// DIN is a drug filled for a disease. Each prescription of the disease fills one of its DINs,
+// sampled by Prob; probs are shares normalised to sum to 1.
 type DIN struct {
 	Prob float64
 	DIN  string
 }
 
+// DrugClass describes a class of drugs identified by an ATC pattern, eg A10%.
+// Prob is the probability of getting a drug from the class; the DIN dispensed
+// is sampled from the DINs in the class by their market share.
+type DrugClass struct {
+	Prob   float64 `json:"prob"`
+	ATC    string  `json:"atc"`
+	lookup *Lookup
+}
+
+type DrugReferenceDescriptor struct {
+	Name      string `json:"variable_name"`
+	FileName  string `json:"csv_filename"`
+	reference *DrugReference
+}
+
 type LookupDescriptor struct {
-	Name     string `json:"variable_name"`
-	FileName string `json:"csv_filename"`
-	lookup   *Lookup
+	Name       string   `json:"variable_name"`
+	FileName   string   `json:"csv_filename"`
+	Attributes []string `json:"attributes"`
+	lookup     *Lookup
 }
 
+// PrevalenceRatio multiplies the prevalence of a disease in people whose location has
+// a given value of a locator attribute, eg urban_rural=rural
+type PrevalenceRatio struct {
+	Attribute string  `json:"attribute"`
+	Value     string  `json:"value"`
+	Ratio     float64 `json:"ratio"`
+}
+
+// LoadConfig loads a configuration file in JSON, YAML or TOML, with its includes, and processes it.
 func LoadConfig(filename string) (*Config, error) {
-	file, err := os.Open(filename)
+	data, err := readConfigDocument(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	config := &Config{}
-	decoder := json.NewDecoder(file)
-	if err = decoder.Decode(config); err != nil {
+	config, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil {
 		return nil, err
 	}
+	for _, warning := range config.warnings {
+		log.Printf("%s: warning: %s", filename, warning)
+	}
 	config.dispatcher = NewDispatcher(bufferSize, config)
 	return ProcessConfig(config)
 }
 
+// keys of the random streams derived from the seed
+const (
+	streamUnits      = iota // people, households or entrants, by index
+	streamProviders         // the provider pool
+	streamEntries           // entry dates of entrants and household sizes
+	streamSubjectIDs        // the permutation of random subject ids
+)
+
+// stream returns the random stream identified by keys, derived from the seed. The same seed
+// and keys always give the same random numbers.
+func (config *Config) stream(keys ...uint64) *rand.Rand {
+	return rand.New(rng.Derive(uint64(config.Seed), keys...))
+}
+
 func ProcessConfig(config *Config) (*Config, error) {
 	var (
 		err  error
 		date time.Time
 	)
+	if config.Version != configVersion {
+		return nil, fmt.Errorf("configuration version %q is not supported. Use version %s or run sim migrate-config", config.Version, configVersion)
+	}
 	if config.N < 1 {
 		return nil, fmt.Errorf("N must be larger than 0")
 	}
@@ -97,10 +265,34 @@ func ProcessConfig(config *Config) (*Config, error) {
 		return nil, err
 	}
 	config.Population.databaseStartDate = date.Unix()
+	// the end date is recorded in the configuration so that the run can be repeated
+	if config.Population.DatabaseEndDate == "" {
+		config.Population.DatabaseEndDate = time.Now().Format(dateLayoutISO)
+	}
+	if date, err = time.Parse("2006-01-02", config.Population.DatabaseEndDate); err != nil {
+		return nil, err
+	}
+	config.Population.databaseEndDate = date.Unix()
+	if config.Population.databaseEndDate <= config.Population.databaseStartDate {
+		return nil, fmt.Errorf("database_end_date must be after database_start_date")
+	}
 	if date, err = time.Parse("2006-01-02", config.Population.EarliestBirthDate); err != nil {
 		return nil, err
 	}
 	config.Population.minDate = date.Unix()
+	if config.Population.PyramidFileName != "" {
+		if config.Population.ageSexGroups, err = loadPyramid(config.Population.PyramidFileName); err != nil {
+			return nil, fmt.Errorf("cannot load population pyramid from [%s]: %s", config.Population.PyramidFileName, err)
+		}
+		if config.Population.PyramidYear == 0 {
+			config.Population.PyramidYear = toTime(config.Population.databaseStartDate).Year()
+		}
+		// population estimates are for July 1 of the reference year
+		config.Population.pyramidDate = time.Date(config.Population.PyramidYear, 7, 1, 0, 0, 0, 0, time.UTC).Unix()
+		if config.Population.pyramid, err = newPyramid(config.Population.ageSexGroups); err != nil {
+			return nil, fmt.Errorf("invalid population pyramid [%s]: %s", config.Population.PyramidFileName, err)
+		}
+	}
 	if len(config.Diseases) == 0 {
 		return nil, fmt.Errorf("Configuration must include at least 1 disease entry")
 	}
@@ -111,6 +303,27 @@ func ProcessConfig(config *Config) (*Config, error) {
 		if disease.Hospitalization == nil {
 			disease.Hospitalization = config.Hospitalization
 		}
+		if strings.TrimSpace(disease.ConditionID) == "" {
+			disease.ConditionID = disease.Name
+		}
+		if disease.FamilialRatio < 0 || disease.HouseholdRatio < 0 {
+			return nil, fmt.Errorf("disease %s: familial_ratio and household_ratio must be >= 0", disease.Name)
+		}
+		if err = disease.Hospitalization.setStayDist(); err != nil {
+			return nil, fmt.Errorf("disease %s: %s", disease.Name, err)
+		}
+		if len(disease.Dins) > 0 && !disease.IndependentDins {
+			probs := make([]float64, len(disease.Dins))
+			for i, din := range disease.Dins {
+				probs[i] = din.Prob
+			}
+			if disease.dins, err = rng.NewAlias(probs, nil); err != nil {
+				return nil, fmt.Errorf("disease %s: invalid dins prob: %s", disease.Name, err)
+			}
+		}
+	}
+	if err = config.Hospitalization.setStayDist(); err != nil {
+		return nil, err
 	}
 	if config.Options.LocationNeeded {
 		if config.Locator == nil {
@@ -122,6 +335,21 @@ func ProcessConfig(config *Config) (*Config, error) {
 		if config.Locator.lookup, err = LoadLookup(config.Locator.FileName, config.Locator.Name, false); err != nil {
 			return nil, fmt.Errorf("cannot load locator codes from [%s]: %s", config.Locator.FileName, err)
 		}
+		for _, name := range config.Locator.Attributes {
+			if !config.Locator.lookup.HasAttribute(name) {
+				return nil, fmt.Errorf("locator attribute %s is not a field of [%s]", name, config.Locator.FileName)
+			}
+		}
+	}
+	for _, disease := range config.Diseases {
+		for _, pr := range disease.PrevalenceRatios {
+			if !config.Options.LocationNeeded || !config.Locator.lookup.HasAttribute(pr.Attribute) {
+				return nil, fmt.Errorf("disease %s: prevalence ratio attribute %s must be a field of the locator csv file and location_needed must be set to true", disease.Name, pr.Attribute)
+			}
+			if pr.Ratio < 0 {
+				return nil, fmt.Errorf("disease %s: prevalence ratio must not be negative", disease.Name)
+			}
+		}
 	}
 	if config.Options.HospLocationNeeded {
 		if config.Hospitalization.Locator == nil {
@@ -133,18 +361,193 @@ func ProcessConfig(config *Config) (*Config, error) {
 		if config.Hospitalization.Locator.lookup, err = LoadLookup(config.Hospitalization.Locator.FileName, config.Hospitalization.Locator.Name, true); err != nil {
 			return nil, fmt.Errorf("cannot load Hospitalization locator ids from [%s]: %s", config.Hospitalization.Locator.FileName, err)
 		}
+		if err = config.Hospitalization.setChooser(config); err != nil {
+			return nil, err
+		}
+	}
+	if config.RxReference != nil {
+		if strings.TrimSpace(config.RxReference.Name) == "" {
+			config.RxReference.Name = "atc"
+		}
+		if config.RxReference.reference, err = LoadDrugReference(config.RxReference.FileName); err != nil {
+			return nil, fmt.Errorf("cannot load rx reference from [%s]: %s", config.RxReference.FileName, err)
+		}
+	}
+	if config.Options.ATCNeeded && config.RxReference == nil {
+		return nil, fmt.Errorf("atc_needed is set to true so Configuration must include a valid rx_reference entry")
+	}
+	for _, disease := range config.Diseases {
+		if len(disease.DrugClasses) > 0 && config.RxReference == nil {
+			return nil, fmt.Errorf("disease %s uses drug_classes so Configuration must include a valid rx_reference entry", disease.Name)
+		}
+		for _, class := range disease.DrugClasses {
+			if class.lookup, err = config.RxReference.reference.Class(class.ATC); err != nil {
+				return nil, fmt.Errorf("disease %s: %s", disease.Name, err)
+			}
+		}
+	}
+	if config.Options.ProviderNeeded {
+		if config.Providers == nil {
+			return nil, fmt.Errorf("provider_needed is set to true so Configuration must include a valid providers entry")
+		}
+		var locator *Lookup
+		if config.Options.LocationNeeded {
+			locator = config.Locator.lookup
+		}
+		if err = config.Providers.generate(locator, config.stream(streamProviders)); err != nil {
+			return nil, err
+		}
+		for _, disease := range config.Diseases {
+			if disease.Specialty != "" && !config.Providers.hasSpecialty(disease.Specialty) {
+				return nil, fmt.Errorf("disease %s: specialty %s is not one of the providers specialties", disease.Name, disease.Specialty)
+			}
+			if disease.ReferralProb < 0 || disease.ReferralProb > 1 {
+				return nil, fmt.Errorf("disease %s: referral_prob must be between 0 and 1", disease.Name)
+			}
+		}
+	}
+	if (config.Mobility != nil || config.Options.AddressHistoryNeeded || config.Options.EncounterLocationNeeded) && !config.Options.LocationNeeded {
+		return nil, fmt.Errorf("mobility, address_history_needed and encounter_location_needed require location_needed to be set to true")
+	}
+	if config.Mobility != nil {
+		if err = config.Mobility.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Households != nil {
+		if err = config.Households.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Demography != nil {
+		if config.Households != nil {
+			return nil, fmt.Errorf("Configuration cannot include both a households and a demography entry")
+		}
+		if err = config.Demography.validate(config.Population.ageSexGroups); err != nil {
+			return nil, err
+		}
+	}
+	if config.Options.FamilyNeeded && config.Households == nil && config.Demography == nil {
+		return nil, fmt.Errorf("family_needed is set to true so Configuration must include a households or a demography entry")
+	}
+	if ids := config.SubjectIDs; ids != nil {
+		if err = ids.validate(config.Seed); err != nil {
+			return nil, err
+		}
+		// the newborns of a demography are not known before the run, which stops if ids run out
+		if ids.size > 0 && uint64(config.N) > ids.size {
+			return nil, fmt.Errorf("subject_ids digits must be larger: %d digits give fewer %s ids than n", ids.Digits, ids.Scheme)
+		}
 	}
 	// define field names to use in csv
-	config.fieldNames = make(map[string]string, 4)
+	config.outputs = []string{"person", "hosp", "clinic", "rx"}
+	config.fieldNames = make(map[string]string, 5)
 	config.fieldNames["person"] = "subject_id,gender,birthdate,age,coverage_start,coverage_end"
 	if config.Options.LocationNeeded {
 		config.fieldNames["person"] += "," + config.Locator.Name
+		for _, name := range config.Locator.Attributes {
+			config.fieldNames["person"] += "," + name
+		}
+	}
+	if config.Options.FamilyNeeded {
+		config.fieldNames["person"] += ",family_id"
+	}
+	if config.Demography != nil {
+		config.fieldNames["person"] += ",death_date"
 	}
 	config.fieldNames["hosp"] = "subject_id,service_date,discharge_date,code"
 	if config.Options.HospLocationNeeded {
 		config.fieldNames["hosp"] += "," + config.Hospitalization.Locator.Name
 	}
 	config.fieldNames["clinic"] = "subject_id,service_date,code"
+	if config.Options.ProviderNeeded {
+		config.fieldNames["clinic"] += ",provider_id,specialty"
+		if config.Options.LocationNeeded {
+			config.fieldNames["clinic"] += ",provider_" + config.Locator.Name
+		}
+	}
 	config.fieldNames["rx"] = "subject_id,service_date,code"
+	if config.Options.ATCNeeded {
+		config.fieldNames["rx"] += "," + config.RxReference.Name
+	}
+	if config.Options.EncounterLocationNeeded {
+		for _, category := range []string{"hosp", "clinic", "rx"} {
+			config.fieldNames[category] += "," + config.Locator.Name
+		}
+	}
+	if config.Options.FamilyNeeded {
+		config.outputs = append(config.outputs, "family")
+		config.fieldNames["family"] = "subject_id,relative_id,relationship"
+	}
+	if config.Options.AddressHistoryNeeded {
+		config.outputs = append(config.outputs, "address")
+		config.fieldNames["address"] = "subject_id," + config.Locator.Name + ",start,end"
+	}
+	if config.Options.TruthNeeded {
+		config.outputs = append(config.outputs, "truth")
+		config.fieldNames["truth"] = "subject_id,disease,onset_date,status"
+	}
+	if config.SubjectIDs != nil && config.SubjectIDs.Crosswalk {
+		config.outputs = append(config.outputs, "crosswalk")
+		config.fieldNames["crosswalk"] = "sequential_id,subject_id"
+	}
 	return config, nil
 }
+
+// setStayDist applies stay_distribution to a stay_length given as {Mean, SD}
+func (h *Hospitalization) setStayDist() error {
+	fromStats, found := stayDistributions[strings.ToLower(h.StayDistribution)]
+	if !found {
+		return fmt.Errorf("unknown stay_distribution: %s. Must be one of normal, lognormal, gamma or weibull", h.StayDistribution)
+	}
+	if fromStats == nil {
+		return nil
+	}
+	if h.StayLength.stats == nil {
+		return fmt.Errorf("stay_distribution %s requires a stay_length given as {Mean, SD}; give the distribution in stay_length instead", h.StayDistribution)
+	}
+	dist, err := fromStats(h.StayLength.stats.Mean, h.StayLength.stats.SD)
+	if err != nil {
+		return fmt.Errorf("stay_length for a %s stay_distribution: %s", h.StayDistribution, err)
+	}
+	h.StayLength.Distribution = dist
+	return nil
+}
+
+func (h *Hospitalization) setChooser(config *Config) error {
+	var (
+		catchment []catchmentRow
+		geoCodes  []string
+		regions   []string
+		err       error
+	)
+	if h.AdultAge == 0 {
+		h.AdultAge = 18
+	}
+	if h.ReturnProb < 0 || h.ReturnProb > 1 {
+		return fmt.Errorf("hospitalization return_prob must be between 0 and 1")
+	}
+	if h.Catchment != "" {
+		if !config.Options.LocationNeeded {
+			return fmt.Errorf("hospitalization catchment_csv_filename is set so location_needed must be set to true")
+		}
+		if catchment, err = loadCatchment(h.Catchment); err != nil {
+			return fmt.Errorf("cannot load hospital catchment from [%s]: %s", h.Catchment, err)
+		}
+		geoCodes = config.Locator.lookup.Codes
+		regions = geoCodes
+		if h.CatchmentAttribute != "" {
+			if !config.Locator.lookup.HasAttribute(h.CatchmentAttribute) {
+				return fmt.Errorf("hospitalization catchment_attribute %s is not a field of [%s]", h.CatchmentAttribute, config.Locator.FileName)
+			}
+			regions = make([]string, len(geoCodes))
+			for i, geoCode := range geoCodes {
+				regions[i] = config.Locator.lookup.Attribute(geoCode, h.CatchmentAttribute)
+			}
+		}
+	}
+	if h.chooser, err = newHospitalChooser(h.Locator.lookup, catchment, geoCodes, regions, h.AdultAge, h.ReturnProb); err != nil {
+		return fmt.Errorf("cannot load hospital catchment from [%s]: %s", h.Catchment, err)
+	}
+	return nil
+}
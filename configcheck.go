@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigError lists every problem found in a configuration file. Each problem starts with the
+// JSON path of the offending value, eg diseases[0].prevalence_male.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%d problem(s) in configuration:\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// configChecker collects configuration problems, and warnings about values that are ignored
+type configChecker struct {
+	problems []string
+	warnings []string
+}
+
+func (c *configChecker) addf(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	c.problems = append(c.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// warnf records a warning about the value at path; warnings do not stop generation
+func (c *configChecker) warnf(path, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, path+": "+fmt.Sprintf(format, args...))
+}
+
+// add records err, if not nil, as a problem of path
+func (c *configChecker) add(path string, err error) {
+	if err != nil {
+		c.addf(path, "%s", err)
+	}
+}
+
+func (c *configChecker) prob(path string, v float64) {
+	if v < 0 || v > 1 {
+		c.addf(path, "must be a probability between 0 and 1, got %v", v)
+	}
+}
+
+func (c *configChecker) nonNegative(path string, v float64) {
+	if v < 0 {
+		c.addf(path, "must not be negative, got %v", v)
+	}
+}
+
+// dist checks the mean and SD of a distribution given as {Mean, SD}; other distributions are
+// checked when read
+func (c *configChecker) dist(path string, d Dist) {
+	if d.stats != nil {
+		c.nonNegative(path+".Mean", d.stats.Mean)
+		c.nonNegative(path+".SD", d.stats.SD)
+	}
+}
+
+func (c *configChecker) date(path, s string) (int64, bool) {
+	date, err := time.Parse(dateLayoutISO, s)
+	if err != nil {
+		c.addf(path, "must be a date formatted as yyyy-mm-dd, got %q", s)
+		return 0, false
+	}
+	return date.Unix(), true
+}
+
+// DecodeConfig reads a configuration in JSON. Unknown fields, values of the wrong type and values
+// out of range are reported together in a *ConfigError; fields that are ignored, such as chronic,
+// are listed in the warnings of the configuration. Files referenced by the configuration are
+// not read; see ProcessConfig.
+func DecodeConfig(r io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, jsonError(data, err)
+	}
+	c := &configChecker{}
+	c.checkSchema("", raw, reflect.TypeOf(Config{}))
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if len(c.problems) == 0 {
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(config); err != nil {
+			return nil, err
+		}
+	} else {
+		// values of the wrong type are left as zero values and unknown fields ignored so that
+		// the other values can be checked; schema problems are already reported
+		_ = decoder.Decode(config)
+	}
+	schemaProblems := len(c.problems)
+	c.checkValues(config)
+	c.dropRepeated(schemaProblems)
+	if len(c.problems) > 0 {
+		return nil, &ConfigError{Problems: c.problems}
+	}
+	config.warnings = c.warnings
+	return config, nil
+}
+
+// dropRepeated drops the problems found after the first n whose path already has a problem
+func (c *configChecker) dropRepeated(n int) {
+	reported := make(map[string]bool, n)
+	for _, problem := range c.problems[:n] {
+		reported[strings.SplitN(problem, ":", 2)[0]] = true
+	}
+	problems := c.problems[:n]
+	for _, problem := range c.problems[n:] {
+		if !reported[strings.SplitN(problem, ":", 2)[0]] {
+			problems = append(problems, problem)
+		}
+	}
+	c.problems = problems
+}
+
+// checkSchema reports the fields of v unknown to type t and the values whose JSON type does not
+// match the Go type they decode into
+func (c *configChecker) checkSchema(path string, v interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v == nil {
+		return
+	}
+	if u, ok := reflect.New(t).Interface().(json.Unmarshaler); ok {
+		data, err := json.Marshal(v)
+		if err == nil {
+			err = u.UnmarshalJSON(data)
+		}
+		c.add(path, err)
+		return
+	}
+	want := ""
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			want = "an object"
+			break
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := obj[key]
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			field, found := fieldByName(fields, key)
+			if !found {
+				if suggestion := closestName(key, fields); suggestion != "" {
+					c.addf(keyPath, "unknown field; did you mean %q?", suggestion)
+				} else {
+					c.addf(keyPath, "unknown field")
+				}
+				continue
+			}
+			c.checkSchema(keyPath, value, field.Type)
+		}
+	case reflect.Slice:
+		arr, ok := v.([]interface{})
+		if !ok {
+			want = "an array"
+			break
+		}
+		for i, value := range arr {
+			c.checkSchema(fmt.Sprintf("%s[%d]", path, i), value, t.Elem())
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			want = "a string"
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			want = "true or false"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			want = "an integer"
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			want = "a number"
+		}
+	}
+	if want != "" {
+		c.addf(path, "must be %s, got %s", want, jsonType(v))
+	}
+}
+
+// jsonFields returns the exported fields of struct type t by JSON name
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// fieldByName finds a field as encoding/json does: an exact match is preferred over a case-insensitive one
+func fieldByName(fields map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	if f, found := fields[key]; found {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// closestName returns the field name within 2 edits of key, if any
+func closestName(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDist || d == bestDist && name < best {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// checkValues reports missing required entries, values out of range and options whose required
+// entries are missing
+func (c *configChecker) checkValues(config *Config) {
+	if config.N < 1 {
+		c.addf("n", "must be larger than 0, got %d", config.N)
+	}
+	if p := config.Population; p == nil {
+		c.addf("population", "is required")
+	} else {
+		c.prob("population.migrant_prob", p.MigrantProb)
+		c.prob("population.cancel_prob", p.CancelProb)
+		start, okStart := c.date("population.database_start_date", p.DatabaseStartDate)
+		earliest, okEarliest := c.date("population.earliest_birth_date", p.EarliestBirthDate)
+		if okStart && okEarliest && earliest > start {
+			c.addf("population.earliest_birth_date", "must not be after database_start_date")
+		}
+		if p.DatabaseEndDate == "" {
+			c.warnf("population.database_end_date", "is not set, so the date of the run is used and runs on other days generate other data")
+		} else if end, ok := c.date("population.database_end_date", p.DatabaseEndDate); ok && okStart && end <= start {
+			c.addf("population.database_end_date", "must be after database_start_date")
+		}
+	}
+	if h := config.Hospitalization; h == nil {
+		c.addf("hospitalization", "is required")
+	} else {
+		c.hospitalization("hospitalization", h)
+	}
+	if len(config.Diseases) == 0 {
+		c.addf("diseases", "must include at least 1 disease")
+	}
+	names := make(map[string]bool)
+	conditionIDs := make(map[string]bool)
+	for i, d := range config.Diseases {
+		path := fmt.Sprintf("diseases[%d]", i)
+		if d == nil {
+			c.addf(path, "must not be null")
+			continue
+		}
+		if strings.TrimSpace(d.Name) == "" {
+			c.addf(path+".name", "is required")
+		} else if names[d.Name] {
+			c.addf(path+".name", "duplicate disease name %s", d.Name)
+		}
+		names[d.Name] = true
+		conditionID := d.ConditionID
+		if strings.TrimSpace(conditionID) == "" {
+			conditionID = d.Name
+		}
+		if conditionID != "" && conditionIDs[conditionID] {
+			c.addf(path+".condition_id", "duplicate condition id %s", conditionID)
+		}
+		conditionIDs[conditionID] = true
+		c.prob(path+".prevalence_male", d.PrevalenceMale)
+		c.prob(path+".prevalence_female", d.PrevalenceFemale)
+		if d.Chronic {
+			c.warnf(path+".chronic", "is not implemented and is ignored")
+		}
+		if d.Recurrence != 0 {
+			c.warnf(path+".recurrence", "is not implemented and is ignored")
+		}
+		c.dist(path+".hospital_rate", d.HospitalRate)
+		c.dist(path+".clinic_rate", d.ClinicRate)
+		c.dist(path+".rx_rate", d.RxRate)
+		dinsSum := 0.0
+		for j, din := range d.Dins {
+			if d.IndependentDins {
+				c.prob(fmt.Sprintf("%s.dins[%d].Prob", path, j), din.Prob)
+			} else {
+				c.nonNegative(fmt.Sprintf("%s.dins[%d].Prob", path, j), din.Prob)
+			}
+			dinsSum += din.Prob
+			if strings.TrimSpace(din.DIN) == "" {
+				c.addf(fmt.Sprintf("%s.dins[%d].DIN", path, j), "is required")
+			}
+		}
+		if len(d.Dins) > 0 && !d.IndependentDins && !(dinsSum > 0) {
+			c.addf(path+".dins", "probs are weights and must not all be 0")
+		}
+		for j, class := range d.DrugClasses {
+			c.prob(fmt.Sprintf("%s.drug_classes[%d].prob", path, j), class.Prob)
+			if strings.TrimSpace(class.ATC) == "" {
+				c.addf(fmt.Sprintf("%s.drug_classes[%d].atc", path, j), "is required")
+			}
+		}
+		if len(d.DrugClasses) > 0 && config.RxReference == nil {
+			c.addf(path+".drug_classes", "requires an rx_reference entry")
+		}
+		for j, pr := range d.PrevalenceRatios {
+			if strings.TrimSpace(pr.Attribute) == "" {
+				c.addf(fmt.Sprintf("%s.prevalence_ratios[%d].attribute", path, j), "is required")
+			}
+			c.nonNegative(fmt.Sprintf("%s.prevalence_ratios[%d].ratio", path, j), pr.Ratio)
+		}
+		if len(d.PrevalenceRatios) > 0 && !config.Options.LocationNeeded {
+			c.addf(path+".prevalence_ratios", "requires location_needed to be set to true")
+		}
+		c.prob(path+".referral_prob", d.ReferralProb)
+		c.nonNegative(path+".familial_ratio", d.FamilialRatio)
+		c.nonNegative(path+".household_ratio", d.HouseholdRatio)
+		if d.Specialty != "" && config.Providers != nil && config.Options.ProviderNeeded && !config.Providers.hasSpecialty(d.Specialty) {
+			c.addf(path+".specialty", "%s is not one of the providers specialties", d.Specialty)
+		}
+		if d.Hospitalization != nil {
+			c.hospitalization(path+".hospitalization", d.Hospitalization)
+		}
+	}
+	if ps := config.Providers; ps != nil {
+		if ps.N < 0 {
+			c.addf("providers.n", "must not be negative, got %d", ps.N)
+		}
+		for i, s := range ps.Specialties {
+			c.prob(fmt.Sprintf("providers.specialties[%d].prob", i), s.Prob)
+		}
+	}
+	if config.Mobility != nil {
+		c.add("mobility", config.Mobility.validate())
+	}
+	if config.Households != nil {
+		c.add("households", config.Households.validate())
+	}
+	if config.Demography != nil {
+		if len(config.Demography.Pyramid) > 0 || config.Population == nil || config.Population.PyramidFileName == "" {
+			c.add("demography", config.Demography.validate(nil))
+		}
+		if config.Households != nil {
+			c.addf("demography", "cannot be used with households")
+		}
+	}
+	if config.SubjectIDs != nil {
+		c.add("subject_ids", config.SubjectIDs.validate(config.Seed))
+	}
+	c.options(config)
+}
+
+func (c *configChecker) hospitalization(path string, h *Hospitalization) {
+	c.dist(path+".stay_length", h.StayLength)
+	if fromStats, found := stayDistributions[strings.ToLower(h.StayDistribution)]; !found {
+		c.addf(path+".stay_distribution", "must be one of normal, lognormal, gamma or weibull, got %q", h.StayDistribution)
+	} else if fromStats != nil && h.StayLength.Name != "" {
+		c.addf(path+".stay_distribution", "applies only to a stay_length given as {Mean, SD}; give the distribution in stay_length instead")
+	}
+	c.prob(path+".return_prob", h.ReturnProb)
+	if h.AdultAge < 0 {
+		c.addf(path+".adult_age", "must not be negative, got %d", h.AdultAge)
+	}
+}
+
+// options reports options whose required entries are missing
+func (c *configChecker) options(config *Config) {
+	o := config.Options
+	requires := []struct {
+		option  string
+		set     bool
+		missing bool
+		entry   string
+	}{
+		{"location_needed", o.LocationNeeded, config.Locator == nil, "a locator entry"},
+		{"hospital_location_needed", o.HospLocationNeeded, config.Hospitalization != nil && config.Hospitalization.Locator == nil, "a hospitalization locator entry"},
+		{"atc_needed", o.ATCNeeded, config.RxReference == nil, "an rx_reference entry"},
+		{"provider_needed", o.ProviderNeeded, config.Providers == nil, "a providers entry"},
+		{"address_history_needed", o.AddressHistoryNeeded, !o.LocationNeeded, "location_needed to be set to true"},
+		{"encounter_location_needed", o.EncounterLocationNeeded, !o.LocationNeeded, "location_needed to be set to true"},
+		{"family_needed", o.FamilyNeeded, config.Households == nil && config.Demography == nil, "a households or a demography entry"},
+	}
+	for _, r := range requires {
+		if r.set && r.missing {
+			c.addf("options."+r.option, "requires %s", r.entry)
+		}
+	}
+	if config.Mobility != nil && !o.LocationNeeded {
+		c.addf("mobility", "requires location_needed to be set to true")
+	}
+}
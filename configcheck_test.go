@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/drgo/sim/simula"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	_, err := DecodeConfig(strings.NewReader(`{
+	"n": 0,
+	"population": {"migrant_prob": 1.5, "cancel_prob": "high", "database_start_date": "1971-01-01", "earliest_birth_date": "1920-01-01"},
+	"hospitalization": {"stay_length": {"Mean": 5, "SD": 2}},
+	"diseases": [{"name": "diabetes", "prevelance_male": 0.1}],
+	"__doc": ["ignored"]
+}`))
+	cerr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	want := []string{
+		`diseases[0].prevelance_male: unknown field; did you mean "prevalence_male"?`,
+		`population.cancel_prob: must be a number, got the string "high"`,
+		"n: must be larger than 0, got 0",
+		"population.migrant_prob: must be a probability between 0 and 1, got 1.5",
+	}
+	if strings.Join(cerr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems\n%s\nwant\n%s", strings.Join(cerr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	_, err = DecodeConfig(strings.NewReader(`{
+	"n": 0,
+	"population": {"migrant_prob": 1.5, "database_start_date": "1971-01-01", "database_end_date": "1970-12-31", "earliest_birth_date": "1920-13-01"},
+	"hospitalization": {"stay_length": {"Mean": 5, "SD": -2}},
+	"diseases": [{"name": "diabetes", "dins": [{"DIN": "02494442", "Prob": -1}]}, {"name": "asthma", "dins": [{"DIN": "02483319", "Prob": 0}]}],
+	"options": {"atc_needed": true}
+}`))
+	if cerr, ok = err.(*ConfigError); !ok {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	want = []string{
+		"n: must be larger than 0, got 0",
+		"population.migrant_prob: must be a probability between 0 and 1, got 1.5",
+		`population.earliest_birth_date: must be a date formatted as yyyy-mm-dd, got "1920-13-01"`,
+		"population.database_end_date: must be after database_start_date",
+		"hospitalization.stay_length.SD: must not be negative, got -2",
+		"diseases[0].dins[0].Prob: must not be negative, got -1",
+		"diseases[0].dins: probs are weights and must not all be 0",
+		"diseases[1].dins: probs are weights and must not all be 0",
+		"options.atc_needed: requires an rx_reference entry",
+	}
+	if strings.Join(cerr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems\n%s\nwant\n%s", strings.Join(cerr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	_, err = DecodeConfig(strings.NewReader(`{
+	"n": 1,
+	"population": {"database_start_date": "1971-01-01", "earliest_birth_date": "1920-01-01"},
+	"hospitalization": {"stay_length": {"Mean": 5, "SD": 2}},
+	"diseases": [{"name": "diabetes", "independent_dins": true, "dins": [{"DIN": "02494442", "Prob": 1.5}, {"DIN": "02483319", "Prob": 0}]}]
+}`))
+	if cerr, ok = err.(*ConfigError); !ok || strings.Join(cerr.Problems, "\n") != "diseases[0].dins[0].Prob: must be a probability between 0 and 1, got 1.5" {
+		t.Errorf("got error %v, want independent dins probs to be probabilities that may all be 0", err)
+	}
+
+	if _, err = DecodeConfig(strings.NewReader("{\n\"n\": 1,\n}")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("got error %v, want a syntax error in line 3", err)
+	}
+}
+
+func TestDecodeConfigDist(t *testing.T) {
+	config, err := DecodeConfig(strings.NewReader(`{
+	"n": 1,
+	"population": {"database_start_date": "1971-01-01", "earliest_birth_date": "1920-01-01"},
+	"hospitalization": {"stay_length": {"dist": "gamma", "shape": 2, "scale": 3, "max": 60}},
+	"diseases": [{"name": "diabetes", "hospital_rate": {"mean": 0.25, "sd": 1}, "rx_rate": {"dist": "poisson", "lambda": 4}}]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Hospitalization.StayLength.Distribution.(*simula.Truncated); !ok {
+		t.Errorf("stay_length is a %T, want a truncated gamma", config.Hospitalization.StayLength.Distribution)
+	}
+	disease := config.Diseases[0]
+	if s := disease.HospitalRate.stats; s == nil || *s != (Stats{0.25, 1}) {
+		t.Errorf("hospital_rate stats = %v, want {0.25 1}", s)
+	}
+	if got := disease.RxRate.Mean(); got != 4 {
+		t.Errorf("rx_rate mean = %v, want 4", got)
+	}
+	if got := disease.ClinicRate.Rand(nil); got != 0 {
+		t.Errorf("missing clinic_rate returned %v, want 0", got)
+	}
+	data, err := json.Marshal(disease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"hospital_rate":{"Mean":0.25,"SD":1}`, `"rx_rate":{"dist":"poisson","lambda":4}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("got %s, want it to contain %s", data, want)
+		}
+	}
+
+	_, err = DecodeConfig(strings.NewReader(`{
+	"n": 1,
+	"population": {"database_start_date": "1971-01-01", "earliest_birth_date": "1920-01-01"},
+	"hospitalization": {"stay_length": {"dist": "gamma", "mean": 5}},
+	"diseases": [{"name": "diabetes", "hospitalization": {"stay_length": {"dist": "gamma", "mean": 5, "sd": 2}, "stay_distribution": "lognormal"}, "hospital_rate": {"dist": "gama", "shape": 2, "scale": 3}, "clinic_rate": {"Mean": 2, "Median": 1}}]
+}`))
+	cerr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	want := []string{
+		`diseases[0].clinic_rate: unknown field "Median"; use {"Mean":m,"SD":s} or a distribution with a dist field`,
+		`diseases[0].hospital_rate: unknown distribution "gama". Must be one of beta, exponential, gamma, gompertz, lognormal, negative_binomial, normal, poisson, truncated_normal, uniform, weibull`,
+		"hospitalization.stay_length: gamma: parameters must be shape and scale, or shape and rate, or mean and sd (and optionally min and max), got mean",
+		"diseases[0].hospitalization.stay_distribution: applies only to a stay_length given as {Mean, SD}; give the distribution in stay_length instead",
+	}
+	if strings.Join(cerr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems\n%s\nwant\n%s", strings.Join(cerr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDecodeConfigWarnings(t *testing.T) {
+	config, err := DecodeConfig(strings.NewReader(`{
+	"n": 1,
+	"population": {"database_start_date": "1971-01-01", "earliest_birth_date": "1920-01-01"},
+	"hospitalization": {"stay_length": {"Mean": 5, "SD": 1}},
+	"diseases": [{"name": "diabetes", "chronic": false}, {"name": "asthma", "chronic": true, "recurrence": 2}]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "population.database_end_date: is not set, so the date of the run is used and runs on other days generate other data\n" +
+		"diseases[1].chronic: is not implemented and is ignored\ndiseases[1].recurrence: is not implemented and is ignored"
+	if got := strings.Join(config.warnings, "\n"); got != want {
+		t.Errorf("got warnings\n%s\nwant\n%s", got, want)
+	}
+}
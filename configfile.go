@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the default configuration files, in order of preference
+var configFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// defaultConfigFile returns the first default configuration file that exists, or config.json
+func defaultConfigFile() string {
+	for _, name := range configFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return configFileNames[0]
+}
+
+// readConfigDocument reads a configuration file in JSON, YAML (.yaml or .yml) or TOML (.toml), merges
+// the files it includes, migrated to the current version, and returns the merged configuration as JSON.
+func readConfigDocument(fileName string) ([]byte, error) {
+	doc, err := loadDocument(fileName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// loadDocument reads a configuration file and the files listed in its include field. Each file is
+// migrated to the current version before it is merged. Included files are merged in order and the
+// including file is merged over them. Include paths are relative to the including file. version is the
+// version field of the including file, which included files without a version have, and including lists
+// the files being loaded, to detect include cycles.
+func loadDocument(fileName string, version interface{}, including []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range including {
+		if name == abs {
+			return nil, fmt.Errorf("%s: include cycle: %s", fileName, strings.Join(append(including, abs), " -> "))
+		}
+	}
+	doc, err := parseDocument(fileName)
+	if err != nil {
+		return nil, err
+	}
+	includes, err := includeList(doc["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	delete(doc, "include")
+	from, version, err := migrateFileDocument(fileName, doc, version)
+	if err != nil {
+		return nil, err
+	}
+	if from != configVersion {
+		log.Printf("%s: configuration version %s was migrated to %s; run sim migrate-config to update the file", fileName, from, configVersion)
+	}
+	merged := map[string]interface{}{}
+	for _, include := range includePaths(fileName, includes) {
+		included, err := loadDocument(include, version, append(including, abs))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeDocuments(merged, included)
+	}
+	return mergeDocuments(merged, doc), nil
+}
+
+// configDocument is the document of a configuration file parsed without merging its includes
+type configDocument struct {
+	name string
+	doc  map[string]interface{}
+	from string // version of the file before migration
+}
+
+// migrateFiles reads a configuration file and the files it includes, recursively, and migrates each
+// like loadDocument but without merging them, for sim migrate-config. The configuration file is first
+// and each file is listed once. seen holds the files already read.
+func migrateFiles(fileName string, version interface{}, seen map[string]bool) ([]*configDocument, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return nil, nil
+	}
+	seen[abs] = true
+	doc, err := parseDocument(fileName)
+	if err != nil {
+		return nil, err
+	}
+	includes, err := includeList(doc["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	from, version, err := migrateFileDocument(fileName, doc, version)
+	if err != nil {
+		return nil, err
+	}
+	files := []*configDocument{{name: fileName, doc: doc, from: from}}
+	for _, include := range includePaths(fileName, includes) {
+		included, err := migrateFiles(include, version, seen)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, included...)
+	}
+	return files, nil
+}
+
+// migrateFileDocument migrates the document of a configuration file and returns its original version
+// and its version field. A file without a version has inherited, the version field of the including
+// file; a file that is not included (inherited is nil) and has no version is version 1.0.
+func migrateFileDocument(fileName string, doc map[string]interface{}, inherited interface{}) (string, interface{}, error) {
+	if _, found := doc["version"]; !found && inherited != nil {
+		doc["version"] = inherited
+	}
+	version := doc["version"]
+	from, err := migrateDocument(doc)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	return from, version, nil
+}
+
+// includePaths returns the paths of the files included by fileName, which are relative to its folder
+func includePaths(fileName string, includes []string) []string {
+	paths := make([]string, len(includes))
+	for i, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(fileName), include)
+		}
+		paths[i] = include
+	}
+	return paths
+}
+
+// parseDocument reads a configuration file without its includes. The format is chosen by the file extension.
+func parseDocument(fileName string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".json":
+		if err = json.Unmarshal(data, &doc); err != nil {
+			err = jsonError(data, err)
+		}
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		_, err = toml.Decode(string(data), &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format %s. Must be .json, .yaml, .yml or .toml", fileName, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	if doc == nil {
+		return map[string]interface{}{}, nil
+	}
+	return normalizeValue(doc).(map[string]interface{}), nil
+}
+
+// writeDocument writes a configuration file in the format chosen by the file extension
+func writeDocument(fileName string, doc map[string]interface{}) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".json":
+		if data, err = json.MarshalIndent(doc, "", "\t"); err == nil {
+			data = append(data, '\n')
+		}
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(doc)
+	case ".toml":
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(doc)
+		data = buf.Bytes()
+	default:
+		return fmt.Errorf("%s: unknown configuration format %s. Must be .json, .yaml, .yml or .toml", fileName, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", fileName, err)
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// rewriteDocument writes a configuration file over fileName, keeping the original with a .bak extension
+func rewriteDocument(fileName string, doc map[string]interface{}) error {
+	if err := os.Rename(fileName, fileName+".bak"); err != nil {
+		return err
+	}
+	return writeDocument(fileName, doc)
+}
+
+// includeList returns the file names of an include field: a file name or an array of file names
+func includeList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		names := make([]string, len(v))
+		for i, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("include[%d] must be a file name", i)
+			}
+			names[i] = s
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("include must be a file name or an array of file names")
+	}
+}
+
+// mergeDocuments merges override into base: objects are merged field by field, diseases are merged
+// by name (diseases not in base are appended) and other values in override replace those in base.
+func mergeDocuments(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if b, ok := merged[key].(map[string]interface{}); ok {
+				merged[key] = mergeDocuments(b, v)
+				continue
+			}
+		case []interface{}:
+			if b, ok := merged[key].([]interface{}); ok && key == "diseases" {
+				merged[key] = mergeDiseases(b, v)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+func mergeDiseases(base, override []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	for _, d := range override {
+		disease, ok := d.(map[string]interface{})
+		found := false
+		for i, b := range merged {
+			if bd, isMap := b.(map[string]interface{}); ok && isMap && bd["name"] != nil && bd["name"] == disease["name"] {
+				merged[i] = mergeDocuments(bd, disease)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// normalizeValue converts YAML and TOML values to JSON values: dates become yyyy-mm-dd strings and
+// maps with non-string keys get string keys
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeValue(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeValue(value)
+		}
+		return v
+	case []map[string]interface{}: // TOML arrays of tables
+		a := make([]interface{}, len(v))
+		for i, value := range v {
+			a[i] = normalizeValue(value)
+		}
+		return a
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(dateLayoutISO)
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// jsonError adds the line number to JSON syntax errors
+func jsonError(data []byte, err error) error {
+	if serr, ok := err.(*json.SyntaxError); ok {
+		line := 1 + bytes.Count(data[:serr.Offset], []byte("\n"))
+		return fmt.Errorf("invalid JSON in line %d: %s", line, err)
+	}
+	return err
+}
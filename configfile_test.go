@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigLibrary(t *testing.T) {
+	config, err := LoadConfig("./config-library.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Diseases) != 3 {
+		t.Fatalf("got %d diseases, want 3", len(config.Diseases))
+	}
+	diabetes := config.Diseases[0]
+	if diabetes.Name != "diabetes" || diabetes.PrevalenceMale != 0.12 || diabetes.Icd10 != "E11.9" || len(diabetes.Dins) != 3 {
+		t.Errorf("diabetes not merged with its override: %+v", diabetes)
+	}
+	if config.Population.DatabaseStartDate != "1971-01-01" {
+		t.Errorf("database_start_date = %q, want 1971-01-01", config.Population.DatabaseStartDate)
+	}
+}
+
+func TestLoadDocument(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"base.toml": `
+n = 10
+[population]
+migrant_prob = 0.1
+database_start_date = 1971-01-01
+[[diseases]]
+name = "asthma"
+icd9 = "493"
+`,
+		"study.json": `{"include": "base.toml", "population": {"cancel_prob": 0.2}, "diseases": [{"name": "asthma", "icd9": "493.9"}, {"name": "cvd"}]}`,
+		"a.yaml":     "include: b.yaml\n",
+		"b.yaml":     "include: [a.yaml]\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc, err := loadDocument(filepath.Join(dir, "study.json"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	population := doc["population"].(map[string]interface{})
+	if population["migrant_prob"] != 0.1 || population["cancel_prob"] != 0.2 || population["database_start_date"] != "1971-01-01" {
+		t.Errorf("population not merged: %v", population)
+	}
+	diseases := doc["diseases"].([]interface{})
+	if len(diseases) != 2 || diseases[0].(map[string]interface{})["icd9"] != "493.9" {
+		t.Errorf("diseases not merged by name: %v", diseases)
+	}
+	if _, err := loadDocument(filepath.Join(dir, "a.yaml"), nil, nil); err == nil {
+		t.Errorf("include cycle not detected")
+	}
+}
+
+func TestMigrateIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"study.json":  `{"version": "1.1", "include": ["copd.yaml", "old.json"]}`,
+		"copd.yaml":   "diseases:\n  - {name: copd, dins: [{DIN: \"2\", Prob: 1}]}\n",
+		"old.json":    `{"version": "1.0", "include": "asthma.json", "diseases": [{"name": "cvd", "dins": [{"DIN": "3", "Prob": 0.5}]}]}`,
+		"asthma.json": `{"diseases": [{"name": "asthma", "dins": [{"DIN": "1", "Prob": 0.5}]}]}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	study := filepath.Join(dir, "study.json")
+	doc, err := loadDocument(study, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// copd.yaml has the version of study.json and asthma.json that of old.json, 1.0
+	for _, d := range doc["diseases"].([]interface{}) {
+		disease := d.(map[string]interface{})
+		if want := disease["name"] != "copd"; (disease["independent_dins"] == true) != want {
+			t.Errorf("%s: independent_dins = %v, want %v", disease["name"], disease["independent_dins"], want)
+		}
+	}
+
+	migrated, err := migrateFiles(study, nil, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range migrated {
+		got = append(got, filepath.Base(f.name)+" "+f.from)
+	}
+	if want := "study.json 1.1, copd.yaml 1.1, old.json 1.0, asthma.json 1.0"; strings.Join(got, ", ") != want {
+		t.Errorf("got files %s, want %s", strings.Join(got, ", "), want)
+	}
+
+	if err = migrateConfigCommand([]string{"-config", study, "-out", filepath.Join(dir, "out.json")}); err == nil {
+		t.Errorf("-out with included files to migrate should be rejected")
+	}
+	if err = migrateConfigCommand([]string{"-config", study}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"old.json", "asthma.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name+".bak")); err != nil {
+			t.Errorf("%s was not migrated: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(study + ".bak"); err == nil {
+		t.Errorf("study.json at version %s was rewritten", configVersion)
+	}
+	if migrated, err = migrateFiles(study, nil, map[string]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range migrated {
+		if f.from != configVersion {
+			t.Errorf("%s is version %s after migration, want %s", f.name, f.from, configVersion)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// configVersion is the version of the configuration schema read by this version of sim
+const configVersion = "1.1"
+
+// configMigration updates a configuration document from version From to version To.
+// migrate returns a note for each change that may alter the generated data.
+type configMigration struct {
+	From, To string
+	migrate  func(doc map[string]interface{}) []string
+}
+
+// configMigrations lists the migrations from the oldest version to configVersion
+var configMigrations = []configMigration{
+	{From: "1.0", To: "1.1", migrate: migrate10},
+}
+
+// migrateDocument updates a configuration document to configVersion and returns its original version.
+// A document without a version is assumed to be version 1.0. Notes on changes are logged. Fields
+// unknown to sim, which version 1.0 ignored, are kept so that DecodeConfig reports them with their
+// path, as they are more likely typos than fields to drop.
+func migrateDocument(doc map[string]interface{}) (string, error) {
+	from, err := documentVersion(doc["version"])
+	if err != nil {
+		return "", err
+	}
+	version := from
+	for _, m := range configMigrations {
+		if version != m.From {
+			continue
+		}
+		for _, note := range m.migrate(doc) {
+			log.Printf("migrating configuration from version %s to %s: %s", m.From, m.To, note)
+		}
+		version = m.To
+	}
+	if version != configVersion {
+		return "", fmt.Errorf("configuration version %s is not supported. Supported versions: %s", from, strings.Join(configVersions(), ", "))
+	}
+	doc["version"] = configVersion
+	return from, nil
+}
+
+// documentVersion returns the version field of a configuration document. YAML and TOML files
+// may hold the version as a number, eg 1.0.
+func documentVersion(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "1.0", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	case int64:
+		return strconv.FormatInt(v, 10) + ".0", nil
+	case int:
+		return strconv.Itoa(v) + ".0", nil
+	default:
+		return "", fmt.Errorf("version must be a string, eg \"%s\", got %s", configVersion, jsonType(v))
+	}
+}
+
+// configVersions returns the supported configuration versions
+func configVersions() []string {
+	versions := make([]string, 0, len(configMigrations)+1)
+	for _, m := range configMigrations {
+		versions = append(versions, m.From)
+	}
+	return append(versions, configVersion)
+}
+
+// migrate10 migrates version 1.0 configurations. In version 1.0, each DIN of a prescription was
+// filled independently with probability prob; now each prescription fills one DIN sampled with prob
+// as its weight unless independent_dins is set, which the migration sets to keep the generated data.
+func migrate10(doc map[string]interface{}) []string {
+	var notes []string
+	diseases, _ := doc["diseases"].([]interface{})
+	for i, d := range diseases {
+		disease, _ := d.(map[string]interface{})
+		if _, set := disease["independent_dins"]; set {
+			continue
+		}
+		if dins, _ := disease["dins"].([]interface{}); len(dins) > 0 {
+			disease["independent_dins"] = true
+			notes = append(notes, fmt.Sprintf("diseases[%d].independent_dins is set to keep filling each DIN with probability prob; remove it to fill one DIN per prescription using prob as a weight", i))
+		}
+	}
+	return notes
+}
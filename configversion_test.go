@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  interface{}
+		wantFrom string
+		wantErr  bool
+	}{
+		{"no version", nil, "1.0", false},
+		{"1.0", "1.0", "1.0", false},
+		{"yaml number", 1.0, "1.0", false},
+		{"current", configVersion, configVersion, false},
+		{"newer", "9.0", "", true},
+		{"not a string", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := map[string]interface{}{
+				"n":      10.0,
+				"colour": "red",
+				"diseases": []interface{}{
+					map[string]interface{}{"name": "asthma", "chronic": true, "severity": 2.0},
+				},
+				"population": map[string]interface{}{"migrant_prob": 0.1, "region": "north"},
+			}
+			if tt.version != nil {
+				doc["version"] = tt.version
+			}
+			from, err := migrateDocument(doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("migrateDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if from != tt.wantFrom {
+				t.Errorf("migrateDocument() = %s, want %s", from, tt.wantFrom)
+			}
+			// unknown fields are kept, to be reported by DecodeConfig
+			want := map[string]interface{}{
+				"version": configVersion,
+				"n":       10.0,
+				"colour":  "red",
+				"diseases": []interface{}{
+					map[string]interface{}{"name": "asthma", "chronic": true, "severity": 2.0},
+				},
+				"population": map[string]interface{}{"migrant_prob": 0.1, "region": "north"},
+			}
+			if !reflect.DeepEqual(doc, want) {
+				t.Errorf("migrated document = %v, want %v", doc, want)
+			}
+		})
+	}
+}
+
+func TestMigrate10(t *testing.T) {
+	doc := map[string]interface{}{"diseases": []interface{}{
+		map[string]interface{}{"name": "asthma"},
+		map[string]interface{}{"name": "diabetes", "dins": []interface{}{map[string]interface{}{"DIN": "02494442", "Prob": 0.5}}},
+	}}
+	notes := migrate10(doc)
+	if len(notes) != 1 || !strings.HasPrefix(notes[0], "diseases[1].independent_dins") {
+		t.Errorf("got notes %q, want one note on diseases[1].independent_dins", notes)
+	}
+	diseases := doc["diseases"].([]interface{})
+	if _, set := diseases[0].(map[string]interface{})["independent_dins"]; set {
+		t.Errorf("independent_dins is set for a disease without dins")
+	}
+	if diseases[1].(map[string]interface{})["independent_dins"] != true {
+		t.Errorf("independent_dins is not set for a disease with dins")
+	}
+}
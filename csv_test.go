@@ -1,37 +1,118 @@
 package main
 
 import (
-	"encoding/csv"
-	"log"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 )
 
-//TODO: fix test
 func Test_person(t *testing.T) {
 	config, err := LoadConfig("./config.json")
 	if err != nil {
-		log.Fatalln("error loading configuration file:", err)
+		t.Fatal(err)
 	}
-	w := csv.NewWriter(os.Stdout)
 	for i := 0; i < 20; i++ {
-		p := NewPerson(config, nil)
-		record := p.toStrings()
-		if err := w.Write(record); err != nil {
-			log.Fatalln("error writing record to csv:", err)
+		u := config.dispatcher.newUnit(i)
+		p := NewPerson(config, u, config.stream(streamUnits, uint64(i)))
+		if p.id != 0 || u.people != 1 {
+			t.Fatalf("person %d: id %d in a unit of %d people, want id 0 of 1", i, p.id, u.people)
+		}
+		if len(u.records["person"]) != 1 {
+			t.Errorf("person %d: %d person records, want 1", i, len(u.records["person"]))
+		}
+		for category, records := range u.records {
+			for _, record := range records {
+				if record[0] != "0" {
+					t.Errorf("person %d: %s record %v is not of the person", i, category, record)
+				}
+			}
 		}
 		for _, v := range p.visits {
-			record = v.toStrings()
-			if err := w.Write(record); err != nil {
-				log.Fatalln("error writing record to csv:", err)
+			if v.startDate < p.regisDate || v.endDate > p.cancelDate {
+				t.Errorf("person %d: stay %v outside coverage", i, v.toStrings())
 			}
 		}
 	}
+}
 
-	// Write any buffered data to the underlying writer (standard output).
-	w.Flush()
-
-	if err := w.Error(); err != nil {
-		log.Fatal(err)
+// TestDeterministicOutput generates data with a fixed database end date, then regenerates it from
+// the configuration of the manifest, as a user would on another day.
+func TestDeterministicOutput(t *testing.T) {
+	data, err := readConfigDocument("./config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files [2]map[string]string // sha256 by file name
+	for i := range files {
+		dir, err := ioutil.TempDir("", "sim")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		config, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			config.N, config.Population.DatabaseEndDate = 200, "2019-12-31"
+		}
+		config.quiet = true
+		config.dispatcher = NewDispatcher(bufferSize, config)
+		if config, err = ProcessConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		if err = run(config, dir); err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := newManifest(config, dir, time.Now(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = json.Marshal(manifest.Config); err != nil {
+			t.Fatal(err)
+		}
+		files[i] = make(map[string]string)
+		for _, f := range manifest.Files {
+			files[i][f.Name] = f.SHA256
+		}
+		if i > 0 {
+			continue
+		}
+		lastID := firstID - 1
+		err = readCSVFile(filepath.Join(dir, "person.csv"), func(rec map[string]string) error {
+			id, _ := strconv.Atoi(rec["subject_id"])
+			if id != lastID+1 {
+				t.Errorf("person %d follows %d", id, lastID)
+			}
+			lastID = id
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, category := range []string{"hosp", "clinic", "rx"} {
+			var last [2]string
+			err = readCSVFile(filepath.Join(dir, category+".csv"), func(rec map[string]string) error {
+				key := [2]string{rec["subject_id"], rec["service_date"]}
+				if key[0] < last[0] || key[0] == last[0] && key[1] < last[1] {
+					t.Errorf("%s: %v follows %v", category, key, last)
+				}
+				last = key
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for name, sum := range files[0] {
+		if files[1][name] != sum {
+			t.Errorf("%s differs when regenerated from the manifest", name)
+		}
 	}
 }
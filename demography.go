@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Demography holds config for generating a population that changes over follow-up through births,
+// deaths and migration
+type Demography struct {
+	Pyramid        []*AgeSexGroup   `json:"pyramid"`
+	FertilityRates []*AgeRate       `json:"fertility_rates"`
+	MortalityRates []*AgeSexGroup   `json:"mortality_rates"`
+	Immigration    []*MigrationFlow `json:"immigration"`
+	Emigration     []*MigrationFlow `json:"emigration"`
+	pyramid        *pyramid
+}
+
+// AgeSexGroup holds a value for males and for females aged MinAge to MaxAge years, eg a count,
+// a proportion or an annual rate
+type AgeSexGroup struct {
+	MinAge int     `json:"min_age"`
+	MaxAge int     `json:"max_age"`
+	Male   float64 `json:"male"`
+	Female float64 `json:"female"`
+}
+
+// MigrationFlow is the number of people entering the database (N), or the annual probability
+// of leaving it (Rate), in each calendar year from From to To
+type MigrationFlow struct {
+	From int     `json:"from"`
+	To   int     `json:"to"`
+	N    int     `json:"n"`
+	Rate float64 `json:"rate"`
+}
+
+// validate validates the demography config. If the demography has no pyramid, the population pyramid
+// is used.
+func (d *Demography) validate(population []*AgeSexGroup) error {
+	if len(d.Pyramid) == 0 {
+		d.Pyramid = population
+	}
+	var err error
+	if d.pyramid, err = newPyramid(d.Pyramid); err != nil {
+		return fmt.Errorf("demography: %s", err)
+	}
+	if err = validateAgeRates("fertility rate", d.FertilityRates); err != nil {
+		return err
+	}
+	for _, g := range d.MortalityRates {
+		if g.MinAge < 0 || g.MaxAge < g.MinAge || g.Male < 0 || g.Male > 1 || g.Female < 0 || g.Female > 1 {
+			return fmt.Errorf("invalid mortality rate for ages %d-%d: rates must be between 0 and 1", g.MinAge, g.MaxAge)
+		}
+	}
+	for _, f := range d.Immigration {
+		if f.To < f.From || f.N < 0 {
+			return fmt.Errorf("invalid immigration flow %d-%d", f.From, f.To)
+		}
+	}
+	for _, f := range d.Emigration {
+		if f.To < f.From || f.Rate < 0 || f.Rate > 1 {
+			return fmt.Errorf("invalid emigration flow %d-%d: rate must be between 0 and 1", f.From, f.To)
+		}
+	}
+	return nil
+}
+
+// entries returns the registration dates of the people entering the database: n people at the
+// database start date followed by the immigrants of each year until the database end date, who
+// enter on a random day of the year.
+func (d *Demography) entries(rnd *rand.Rand, n int, databaseStartDate, databaseEndDate int64) []int64 {
+	dates := make([]int64, n, n+len(d.Immigration))
+	for i := range dates {
+		dates[i] = databaseStartDate
+	}
+	for _, f := range d.Immigration {
+		for year := f.From; year <= f.To; year++ {
+			start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+			end := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+			if start < databaseStartDate {
+				start = databaseStartDate
+			}
+			if end > databaseEndDate {
+				end = databaseEndDate
+			}
+			if start >= end {
+				continue
+			}
+			for i := 0; i < f.N; i++ {
+				dates = append(dates, RangeDate(rnd, start, end))
+			}
+		}
+	}
+	return dates
+}
+
+// mortality returns the annual probability of death at age for sex
+func (d *Demography) mortality(age, sex int) float64 {
+	for _, g := range d.MortalityRates {
+		if age >= g.MinAge && age <= g.MaxAge {
+			if sex == 1 {
+				return g.Female
+			}
+			return g.Male
+		}
+	}
+	return 0
+}
+
+// emigration returns the annual probability of leaving the database in year
+func (d *Demography) emigration(year int) float64 {
+	for _, f := range d.Emigration {
+		if year >= f.From && year <= f.To {
+			return f.Rate
+		}
+	}
+	return 0
+}
+
+// NewEntrant generates a person entering the database on regisDate with a sex and age drawn from
+// the pyramid, their life in the database and their descendants born in it, using the random numbers of rnd.
+// The entrant and their descendants are unit u.
+func NewEntrant(config *Config, u *unit, regisDate int64, rnd *rand.Rand) {
+	defer u.done()
+	p := &Person{
+		config:    config,
+		unit:      u,
+		id:        u.newID(),
+		regisDate: regisDate,
+		visits:    []*Visit{},
+		rnd:       rnd,
+	}
+	p.sex, p.dob = config.Demography.pyramid.sample(rnd, regisDate)
+	if p.dob < config.Population.minDate {
+		p.dob = config.Population.minDate
+	}
+	(&family{id: int64(u.index + 1)}).add(p, roleHead)
+	births := p.live()
+	p.generate()
+	p.bear(births)
+}
+
+// live simulates the person's death, emigration and births from registration to the database end
+// date and returns the birth dates of the person's children. A person leaves the database on death
+// or emigration. Each year, a woman gives birth with the fertility rate for her age.
+func (p *Person) live() []int64 {
+	d := p.config.Demography
+	year := int64(secondsInDay * daysInYear)
+	databaseEnd := p.config.Population.databaseEndDate
+	p.cancelDate = databaseEnd
+	var births []int64
+	for t := p.regisDate; t < databaseEnd; t += year {
+		end := t + year
+		if end > databaseEnd {
+			end = databaseEnd
+		}
+		fraction := float64(end-t) / float64(year) // of a year at risk
+		age := ageAt(p.dob, t)
+		if p.rnd.Float64() < d.mortality(age, p.sex)*fraction {
+			p.dod = RangeDate(p.rnd, t, end)
+			p.cancelDate = p.dod
+			break
+		}
+		if p.rnd.Float64() < d.emigration(toTime(t).Year())*fraction {
+			p.cancelDate = RangeDate(p.rnd, t, end)
+			break
+		}
+		if p.sex == 1 && p.rnd.Float64() < rateAt(d.FertilityRates, age)*fraction {
+			births = append(births, RangeDate(p.rnd, t, end))
+		}
+	}
+	p.age = p.config.Population.ageAtEnd(p.dob)
+	return births
+}
+
+// bear generates the children born to the person on dates. Newborns join the database at birth, belong
+// to their mother's family and live at their mother's address while she is in the database.
+func (p *Person) bear(dates []int64) {
+	for _, date := range dates {
+		p.newborn(date)
+	}
+}
+
+// newborn generates a child born to the person on date and the child's descendants
+func (p *Person) newborn(date int64) {
+	child := &Person{
+		config:    p.config,
+		unit:      p.unit,
+		id:        p.unit.newID(),
+		sex:       RangeInt(p.rnd, 0, 1),
+		dob:       date,
+		regisDate: date,
+		visits:    []*Visit{},
+		rnd:       p.rnd,
+	}
+	p.family.add(child, roleChild)
+	if p.config.Options.FamilyNeeded {
+		p.unit.SaveFamily([]string{strconv.Itoa(int(child.id)), strconv.Itoa(int(p.id)), "mother"})
+		p.unit.SaveFamily([]string{strconv.Itoa(int(p.id)), strconv.Itoa(int(child.id)), "child"})
+	}
+	births := child.live()
+	if p.config.Options.LocationNeeded {
+		child.addresses = clipAddresses(p.addresses, child.regisDate, child.cancelDate)
+		if n := len(child.addresses); n > 0 {
+			child.geoCode = child.addresses[n-1].geoCode
+		} else {
+			// born as the mother's last address ended, or left the database at birth
+			child.geoCode = p.geoCodeAt(date)
+			if child.cancelDate > child.regisDate {
+				child.addresses = []address{{geoCode: child.geoCode, start: child.regisDate, end: child.cancelDate}}
+			}
+		}
+	}
+	child.generate()
+	child.bear(births)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDemography(t *testing.T) {
+	d := &Demography{
+		Pyramid:        []*AgeSexGroup{{MinAge: 0, MaxAge: 49, Male: 0.5, Female: 0.5}},
+		FertilityRates: []*AgeRate{{MinAge: 20, MaxAge: 29, Rate: 1}},
+		MortalityRates: []*AgeSexGroup{{MinAge: 70, MaxAge: 120, Male: 1, Female: 1}},
+		Immigration:    []*MigrationFlow{{From: 2000, To: 2001, N: 3}},
+	}
+	if err := d.validate(nil); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	population := &Population{databaseStartDate: start, databaseEndDate: end}
+	rnd := rand.New(rand.NewSource(1))
+	if got := len(d.entries(rnd, 10, start, end)); got != 16 {
+		t.Errorf("got %d entries, want 10 initial and 6 immigrants", got)
+	}
+	year := int64(secondsInDay * daysInYear)
+	mother := &Person{config: &Config{Demography: d, Population: population}, sex: 1, regisDate: start, dob: start - 15*year, rnd: rnd}
+	births := mother.live()
+	// 10 years of fertility (ages 20 to 29); no deaths before 70
+	if len(births) != 10 || mother.dod != 0 || mother.cancelDate != end {
+		t.Errorf("got %d births, death %d, want 10 births and no death", len(births), mother.dod)
+	}
+	old := &Person{config: &Config{Demography: d, Population: population}, regisDate: start, dob: start - 75*year, rnd: rnd}
+	if old.live(); old.dod == 0 || old.dod >= start+year || old.cancelDate != old.dod {
+		t.Errorf("a 75 year old with a mortality rate of 1 must die in the first year")
+	}
+	d.Emigration = []*MigrationFlow{{From: 1900, To: 2100, Rate: 2}}
+	if err := d.validate(nil); err == nil {
+		t.Errorf("an emigration rate > 1 should fail")
+	}
+}
+
+func TestNewbornAddress(t *testing.T) {
+	d := &Demography{MortalityRates: []*AgeSexGroup{{MinAge: 0, MaxAge: 120}}}
+	end := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	config := &Config{Demography: d, Population: &Population{databaseEndDate: end}, Locator: &LookupDescriptor{Name: "postal_code"}}
+	config.Options.LocationNeeded = true
+	year := int64(secondsInDay * daysInYear)
+	birth := end - year
+	mother := &Person{config: config, unit: &unit{records: make(map[string][][]string)}, sex: 1,
+		regisDate: birth - year, cancelDate: birth, geoCode: "B", rnd: rand.New(rand.NewSource(1)),
+		addresses: []address{{geoCode: "A", start: birth - year, end: birth}}}
+	(&family{id: 1}).add(mother, roleHead)
+	// the mother's last address ends on the birth date
+	mother.newborn(birth)
+	child := mother.family.members[1]
+	if child.geoCode != "B" || len(child.addresses) != 1 || child.addresses[0].start != birth {
+		t.Errorf("newborn geocode %q and addresses %v, want B from birth", child.geoCode, child.addresses)
+	}
+}
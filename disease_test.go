@@ -14,6 +14,7 @@ func TestLoadConfig(t *testing.T) {
 		wantErr bool
 	}{
 		{"./config.json", nil, false},
+		{"./examples/full.json", nil, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.args, func(t *testing.T) {
@@ -36,7 +37,7 @@ func TestConfigMarshal(t *testing.T) {
 	config := Config{Diseases: []*Disease{
 		&Disease{
 			Name:         "diabetes",
-			HospitalRate: Stats{3, 1},
+			HospitalRate: statsDist(Stats{3, 1}),
 		},
 	}}
 	json, err := json.MarshalIndent(config, "", " ")
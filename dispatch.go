@@ -2,51 +2,207 @@ package main
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
-	"sync/atomic"
 )
 
+const (
+	firstID      = 1000_001 // subject id of the first person
+	pendingUnits = 1000     // max units generated but not yet written
+)
+
+// Dispatcher sends the records of units to the writers of the csv files. Units are generated
+// concurrently but written in the order of their index, and sequential ids are given in that order,
+// so the same configuration and seed always give the same files.
 type Dispatcher struct {
-	config     *Config
-	bufferSize int
-	lastID     int64
-	wg         sync.WaitGroup
-	personCh   chan []string
-	hospCh     chan []string
-	clinicCh   chan []string
-	rxCh       chan []string
+	config      *Config
+	bufferSize  int
+	wg          sync.WaitGroup
+	units       chan *unit    // generated units
+	window      chan struct{} // one token per unit generated but not yet written
+	personCh    chan []string
+	hospCh      chan []string
+	clinicCh    chan []string
+	rxCh        chan []string
+	truthCh     chan []string
+	addressCh   chan []string
+	familyCh    chan []string
+	crosswalkCh chan []string
+	err         error // stops the records being sent, eg when subject ids run out
 }
 
 func NewDispatcher(bufferSize int, config *Config) *Dispatcher {
 	return &Dispatcher{
-		config:     config,
-		bufferSize: bufferSize,
-		lastID:     1000_000,
-		personCh:   make(chan []string, bufferSize),
-		hospCh:     make(chan []string, bufferSize),
-		clinicCh:   make(chan []string, bufferSize),
-		rxCh:       make(chan []string, bufferSize),
+		config:      config,
+		bufferSize:  bufferSize,
+		units:       make(chan *unit, pendingUnits),
+		window:      make(chan struct{}, pendingUnits),
+		personCh:    make(chan []string, bufferSize),
+		hospCh:      make(chan []string, bufferSize),
+		clinicCh:    make(chan []string, bufferSize),
+		rxCh:        make(chan []string, bufferSize),
+		truthCh:     make(chan []string, bufferSize),
+		addressCh:   make(chan []string, bufferSize),
+		familyCh:    make(chan []string, bufferSize),
+		crosswalkCh: make(chan []string, bufferSize),
+	}
+}
+
+// unit buffers the records of a person, a household or a demography entrant and their descendants.
+// Its people get ids 0, 1, ... in the order they are generated; the dispatcher replaces them with
+// subject ids when it writes the unit.
+type unit struct {
+	dispatcher *Dispatcher
+	index      int
+	people     int64
+	records    map[string][][]string // by category
+}
+
+// newUnit returns the unit of the given index, waiting while too many units are not yet written
+func (d *Dispatcher) newUnit(index int) *unit {
+	d.window <- struct{}{}
+	d.wg.Add(1)
+	return &unit{dispatcher: d, index: index, records: make(map[string][][]string)}
+}
+
+// newID returns the id of a new person of the unit
+func (u *unit) newID() int64 {
+	u.people++
+	return u.people - 1
+}
+
+// done hands the unit over to be written
+func (u *unit) done() {
+	u.dispatcher.units <- u
+	u.dispatcher.wg.Done()
+}
+
+func (u *unit) SavePerson(records []string) {
+	u.records["person"] = append(u.records["person"], records)
+}
+
+func (u *unit) SaveHosp(records []string) {
+	u.records["hosp"] = append(u.records["hosp"], records)
+}
+
+func (u *unit) SaveClinic(records []string) {
+	u.records["clinic"] = append(u.records["clinic"], records)
+}
+
+func (u *unit) SaveRx(records []string) {
+	u.records["rx"] = append(u.records["rx"], records)
+}
+
+func (u *unit) SaveTruth(records []string) {
+	u.records["truth"] = append(u.records["truth"], records)
+}
+
+func (u *unit) SaveAddress(records []string) {
+	u.records["address"] = append(u.records["address"], records)
+}
+
+func (u *unit) SaveFamily(records []string) {
+	u.records["family"] = append(u.records["family"], records)
+}
+
+// dispatch sends the records of the units to the writers in the order of the units until the
+// units channel is closed, then closes the channels of the writers. The records of a unit are
+// sorted by person, then service date if any, so each csv file is in the order of the sequential
+// ids, then of service date, without holding more than pendingUnits units in memory. Subject ids
+// of other schemes than sequential are not in that order. After an error, set in
+// d.err, the units are received but their records are not sent.
+func (d *Dispatcher) dispatch() {
+	dateColumns := make(map[string]int)
+	for category, fieldNames := range d.config.fieldNames {
+		dateColumns[category] = -1
+		for i, name := range strings.Split(fieldNames, ",") {
+			if name == "service_date" {
+				dateColumns[category] = i
+			}
+		}
+	}
+	pending := make(map[int]*unit)
+	next, offset := 0, int64(0)
+	for u := range d.units {
+		pending[u.index] = u
+		for u = pending[next]; u != nil; u = pending[next] {
+			delete(pending, next)
+			for category, records := range u.records {
+				if d.err != nil {
+					break
+				}
+				d.err = d.send(category, records, dateColumns[category], offset)
+			}
+			offset += u.people
+			next++
+			<-d.window
+		}
 	}
+	d.closeAll()
 }
 
-func (d *Dispatcher) SavePerson(records []string) {
-	d.personCh <- records
+// send sorts records of category by id and date (if date >= 0), gives them the subject ids of the
+// people and sends them to the writer of category, and the sequential and subject ids of the people
+// to the writer of the crosswalk if any
+func (d *Dispatcher) send(category string, records [][]string, date int, offset int64) error {
+	ids := make([]int64, len(records))
+	for i, record := range records {
+		ids[i] = localID(record[0])
+	}
+	sort.Stable(byIDAndDate{records, ids, date})
+	qu, err := d.getQbyId(category)
+	if err != nil {
+		panic(err)
+	}
+	scheme := d.config.SubjectIDs
+	crosswalk := category == "person" && scheme != nil && scheme.Crosswalk
+	for i, record := range records {
+		if record[0], err = scheme.id(offset + ids[i]); err != nil {
+			return err
+		}
+		if category == "family" {
+			if record[1], err = scheme.id(offset + localID(record[1])); err != nil {
+				return err
+			}
+		}
+		if crosswalk {
+			d.crosswalkCh <- []string{scheme.sequentialID(offset + ids[i]), record[0]}
+		}
+		qu <- record
+	}
+	return nil
 }
 
-func (d *Dispatcher) SaveHosp(records []string) {
-	d.hospCh <- records
+// localID returns the id of a person within its unit as formatted in its records
+func localID(s string) int64 {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid person id %q in a record", s))
+	}
+	return id
 }
 
-func (d *Dispatcher) SaveClinic(records []string) {
-	d.clinicCh <- records
+// byIDAndDate sorts records by id, then by the date in column date if date >= 0
+type byIDAndDate struct {
+	records [][]string
+	ids     []int64
+	date    int
 }
 
-func (d *Dispatcher) SaveRx(records []string) {
-	d.rxCh <- records
+func (s byIDAndDate) Len() int { return len(s.records) }
+
+func (s byIDAndDate) Less(i, j int) bool {
+	if s.ids[i] != s.ids[j] {
+		return s.ids[i] < s.ids[j]
+	}
+	return s.date >= 0 && s.records[i][s.date] < s.records[j][s.date]
 }
 
-func (d *Dispatcher) getLastID() int64 {
-	return atomic.AddInt64(&d.lastID, 1)
+func (s byIDAndDate) Swap(i, j int) {
+	s.records[i], s.records[j] = s.records[j], s.records[i]
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
 }
 
 func (d *Dispatcher) getQbyId(category string) (chan []string, error) {
@@ -59,6 +215,14 @@ func (d *Dispatcher) getQbyId(category string) (chan []string, error) {
 		return d.clinicCh, nil
 	case "rx":
 		return d.rxCh, nil
+	case "truth":
+		return d.truthCh, nil
+	case "address":
+		return d.addressCh, nil
+	case "family":
+		return d.familyCh, nil
+	case "crosswalk":
+		return d.crosswalkCh, nil
 	default:
 		return nil, fmt.Errorf("no such output category: %s", category)
 	}
@@ -69,4 +233,8 @@ func (d *Dispatcher) closeAll() {
 	close(d.hospCh)
 	close(d.clinicCh)
 	close(d.rxCh)
+	close(d.truthCh)
+	close(d.addressCh)
+	close(d.familyCh)
+	close(d.crosswalkCh)
 }
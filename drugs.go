@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/drgo/sim/identify"
+)
+
+// DrugReference maps DINs to their ATC codes and holds the market share of each DIN.
+type DrugReference struct {
+	DINs     []string
+	ATCs     []string
+	Shares   []float64
+	atcByDIN map[string]string
+}
+
+// LoadDrugReference loads a DIN->ATC reference table from a csv file with the fields
+// atc and din, and an optional share field holding the market share of the DIN within its class.
+// If the share field is missing, all DINs in a class are equally likely to be dispensed.
+func LoadDrugReference(fileName string) (*DrugReference, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ref := &DrugReference{atcByDIN: make(map[string]string)}
+	csv := csv.NewReader(file)
+	// Lines beginning with "/" without preceding whitespace are ignored.
+	csv.Comment = '/'
+	csv.ReuseRecord = true // for performance
+	record, err := csv.Read()
+	switch {
+	case err == io.EOF:
+		return nil, fmt.Errorf("empty csv file")
+	case err != nil:
+		return nil, err
+	}
+	atcCol, dinCol, shareCol := -1, -1, -1
+	for i, name := range record {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "atc":
+			atcCol = i
+		case "din":
+			dinCol = i
+		case "share":
+			shareCol = i
+		}
+	}
+	if atcCol < 0 || dinCol < 0 {
+		return nil, fmt.Errorf("required field names are missing. The file must have fields named 'atc' and 'din'")
+	}
+	recNum := 1
+	for {
+		record, err := csv.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recNum++
+		atc := strings.ToUpper(strings.TrimSpace(record[atcCol]))
+		din := strings.TrimSpace(record[dinCol])
+		if atc == "" || din == "" {
+			return nil, fmt.Errorf("missing atc or din in line number %d", recNum)
+		}
+		if _, found := ref.atcByDIN[din]; found {
+			return nil, fmt.Errorf("duplicate din %s in line number %d", din, recNum)
+		}
+		share := 1.0
+		if shareCol >= 0 && strings.TrimSpace(record[shareCol]) != "" {
+			if share, err = strconv.ParseFloat(strings.TrimSpace(record[shareCol]), 64); err != nil {
+				return nil, fmt.Errorf("invalid share in line number %d: %s", recNum, err)
+			}
+			if share < 0 {
+				return nil, fmt.Errorf("negative share in line number %d", recNum)
+			}
+		}
+		ref.DINs = append(ref.DINs, din)
+		ref.ATCs = append(ref.ATCs, atc)
+		ref.Shares = append(ref.Shares, share)
+		ref.atcByDIN[din] = atc
+	}
+	if len(ref.DINs) == 0 {
+		return nil, fmt.Errorf("no drugs found")
+	}
+	return ref, nil
+}
+
+// ATC returns the ATC code of a DIN or an empty string if the DIN is not in the reference.
+func (r *DrugReference) ATC(din string) string {
+	return r.atcByDIN[din]
+}
+
+// Class returns a lookup that samples the DINs whose ATC code matches pattern by their market share.
+func (r *DrugReference) Class(pattern string) (*Lookup, error) {
+	var (
+		dins   []string
+		shares []float64
+	)
+	for i, atc := range r.ATCs {
+		if identify.Match(atc, pattern) {
+			dins = append(dins, r.DINs[i])
+			shares = append(shares, r.Shares[i])
+		}
+	}
+	if len(dins) == 0 {
+		return nil, fmt.Errorf("no DINs match ATC pattern %s", pattern)
+	}
+	return newLookup("din", dins, shares)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDrugReferenceClass(t *testing.T) {
+	ref, err := LoadDrugReference("./rx-reference-lookup.csv")
+	if err != nil {
+		t.Fatalf("LoadDrugReference() error = %v", err)
+	}
+	if got := ref.ATC("00586714"); got != "A10BA02" {
+		t.Errorf("ATC() = %v, want A10BA02", got)
+	}
+	class, err := ref.Class("A10BB03")
+	if err != nil {
+		t.Fatalf("Class() error = %v", err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if atc := ref.ATC(class.RandCode(rnd)); atc != "A10BB03" {
+			t.Fatalf("Class() sampled a DIN with ATC %s", atc)
+		}
+	}
+	if _, err := ref.Class("N02%"); err == nil {
+		t.Errorf("Class() with no matching DINs should fail")
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 )
 
@@ -13,6 +15,8 @@ type Visit struct {
 	endDate   int64
 	diagnosis string
 	hospID    string
+	geoCode   string // patient's geocode on the service date
+	provider  *Provider
 }
 
 func (v *Visit) toStrings() []string {
@@ -27,6 +31,15 @@ func (v *Visit) toStrings() []string {
 		}
 	} else {
 		a = append(a, v.diagnosis)
+		if v.config.Options.ProviderNeeded {
+			a = append(a, v.provider.id, v.provider.specialty)
+			if v.config.Options.LocationNeeded {
+				a = append(a, v.provider.geoCode)
+			}
+		}
+	}
+	if v.config.Options.EncounterLocationNeeded {
+		a = append(a, v.geoCode)
 	}
 	return a
 }
@@ -36,51 +49,115 @@ func (p *Person) newVisit(kind int, disease *Disease, incidenceDate int64) *Visi
 		config:    p.config,
 		kind:      kind,
 		id:        p.id,
-		startDate: RangeDate(incidenceDate, p.cancelDate),
+		startDate: RangeDate(p.rnd, incidenceDate, p.cancelDate),
 	}
 	switch kind {
 	case kindHospital:
-		v.endDate = v.startDate + int64(Normal(disease.Hospitalization.StayLength.Mean, disease.Hospitalization.StayLength.SD))*secondsInDay
+		v.endDate = v.startDate + stayLength(p.rnd, disease.Hospitalization)
 		v.diagnosis = disease.Icd10
-		if v.config.Options.HospLocationNeeded {
-			v.hospID = v.config.Hospitalization.Locator.lookup.RandCode()
-		}
 	default:
 		// v.endDate = stataMissingInt64 //default to missing
 		v.diagnosis = disease.Icd9
+		v.geoCode = p.geoCodeAt(v.startDate)
+		if v.config.Options.ProviderNeeded {
+			v.provider = p.providerFor(disease)
+		}
 	}
 	return &v
 }
 
+// stayLength returns a random length of stay in seconds; stays are at least 1 day long.
+func stayLength(rnd *rand.Rand, h *Hospitalization) int64 {
+	days := int64(math.Round(h.StayLength.Rand(rnd)))
+	if days < 1 {
+		days = 1
+	}
+	return days * secondsInDay
+}
+
+// scheduleStays orders a person's hospital stays so that they do not overlap: an admission
+// during a previous stay is moved to the day after its discharge. Stays are truncated at the
+// end of coverage and stays that would start less than a day before it are dropped.
+func (p *Person) scheduleStays() {
+	sort.Slice(p.visits, func(i, j int) bool { return p.visits[i].startDate < p.visits[j].startDate })
+	stays := p.visits[:0]
+	lastEnd := int64(math.MinInt64 + secondsInDay)
+	for _, v := range p.visits {
+		if v.startDate < lastEnd+secondsInDay {
+			los := v.endDate - v.startDate
+			v.startDate = lastEnd + secondsInDay
+			v.endDate = v.startDate + los
+		}
+		if v.startDate+secondsInDay > p.cancelDate {
+			continue
+		}
+		if v.endDate > p.cancelDate {
+			v.endDate = p.cancelDate
+		}
+		stays = append(stays, v)
+		lastEnd = v.endDate
+	}
+	p.visits = stays
+}
+
 type Rx struct {
 	Drugs []*Drug
 }
 
 type Drug struct {
-	id   int64
-	date int64
-	din  string
+	config  *Config
+	id      int64
+	date    int64
+	din     string
+	atc     string
+	geoCode string // patient's geocode on the service date
 }
 
 func (p *Person) newRx(disease *Disease, incidenceDate int64) *Rx {
-	date := RangeDate(incidenceDate, p.cancelDate)
+	date := RangeDate(p.rnd, incidenceDate, p.cancelDate)
 	var r Rx
-	for _, din := range disease.Dins {
-		if rand.Float64() < din.Prob {
-			r.Drugs = append(r.Drugs, &Drug{
-				id:   p.id,
-				date: date,
-				din:  din.DIN,
-			})
+	switch {
+	case disease.IndependentDins:
+		for _, din := range disease.Dins {
+			if p.rnd.Float64() < din.Prob {
+				r.Drugs = append(r.Drugs, p.newDrug(date, din.DIN))
+			}
+		}
+	case disease.dins != nil:
+		r.Drugs = append(r.Drugs, p.newDrug(date, disease.Dins[disease.dins.SampleRand(p.rnd)].DIN))
+	}
+	for _, class := range disease.DrugClasses {
+		if p.rnd.Float64() < class.Prob {
+			r.Drugs = append(r.Drugs, p.newDrug(date, class.lookup.RandCode(p.rnd)))
 		}
 	}
 	return &r
 }
 
+func (p *Person) newDrug(date int64, din string) *Drug {
+	d := Drug{
+		config:  p.config,
+		id:      p.id,
+		date:    date,
+		din:     din,
+		geoCode: p.geoCodeAt(date),
+	}
+	if p.config.RxReference != nil {
+		d.atc = p.config.RxReference.reference.ATC(din)
+	}
+	return &d
+}
+
 func (d *Drug) toStrings() []string {
 	a := []string{}
 	a = append(a, strconv.Itoa(int(d.id)))
 	a = append(a, toTime(d.date).Format(dateLayoutISO))
 	a = append(a, d.din)
+	if d.config.Options.ATCNeeded {
+		a = append(a, d.atc)
+	}
+	if d.config.Options.EncounterLocationNeeded {
+		a = append(a, d.geoCode)
+	}
 	return a
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drgo/sim/rng"
+)
+
+func TestScheduleStays(t *testing.T) {
+	day := int64(secondsInDay)
+	p := &Person{
+		cancelDate: 100 * day,
+		visits: []*Visit{
+			{kind: kindHospital, startDate: 50 * day, endDate: 60 * day},
+			{kind: kindHospital, startDate: 10 * day, endDate: 20 * day},
+			{kind: kindHospital, startDate: 15 * day, endDate: 18 * day},
+			{kind: kindHospital, startDate: 95 * day, endDate: 105 * day},
+			{kind: kindHospital, startDate: 99*day + 1, endDate: 101 * day},
+		},
+	}
+	p.scheduleStays()
+	want := [][2]int64{{10, 20}, {21, 24}, {50, 60}, {95, 100}}
+	if len(p.visits) != len(want) {
+		t.Fatalf("got %d stays, want %d", len(p.visits), len(want))
+	}
+	for i, v := range p.visits {
+		if v.startDate != want[i][0]*day || v.endDate != want[i][1]*day {
+			t.Errorf("stay %d = [%d, %d] days, want %v", i, v.startDate/day, v.endDate/day, want[i])
+		}
+	}
+}
+
+func TestNewRx(t *testing.T) {
+	day := int64(secondsInDay)
+	p := &Person{config: &Config{}, cancelDate: 100 * day, rnd: rand.New(rand.NewSource(1))}
+	disease := &Disease{Dins: []DIN{{DIN: "1", Prob: 1}, {DIN: "2", Prob: 1}, {DIN: "3", Prob: 0}}, IndependentDins: true}
+	// each DIN is filled with probability prob
+	for i := 0; i < 100; i++ {
+		if r := p.newRx(disease, 0); len(r.Drugs) != 2 || r.Drugs[0].din != "1" || r.Drugs[1].din != "2" {
+			t.Fatalf("independent dins: got %d drugs, want DINs 1 and 2", len(r.Drugs))
+		}
+	}
+	// one DIN is sampled by weight
+	disease.IndependentDins = false
+	var err error
+	if disease.dins, err = rng.NewAlias([]float64{1, 1, 0}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if r := p.newRx(disease, 0); len(r.Drugs) != 1 || r.Drugs[0].din == "3" {
+			t.Fatalf("weighted dins: got %d drugs, want one of DINs 1 and 2", len(r.Drugs))
+		}
+	}
+}
@@ -1,12 +1,9 @@
 module github.com/drgo/sim
 
-go 1.14
-
-require gonum.org/v1/gonum v0.7.0
+go 1.18
 
 require (
-	github.com/drgo/alias v0.0.0-20200407195517-b167525ba025 // indirect
-	github/drgo/alias v0.0.0
+	github.com/BurntSushi/toml v1.3.2
+	gonum.org/v1/gonum v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
 )
-
-replace github/drgo/alias => ../aliasmethod
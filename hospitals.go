@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/drgo/sim/identify"
+)
+
+const (
+	classAdult = "adult"
+	classPeds  = "peds"
+)
+
+// hospitalChooser picks the hospital of an admission based on the patient's age class (peds or adult),
+// the catchment of the patient's postal code and the hospital of the patient's previous admission.
+type hospitalChooser struct {
+	all        *Lookup
+	classOf    map[string]string
+	byClass    map[string]*Lookup
+	byGeoCode  map[string]map[string]*Lookup // geocode -> class -> hospitals in the catchment of geocode
+	adultAge   int
+	returnProb float64
+}
+
+type catchmentRow struct {
+	region string
+	hospID string
+	freq   float64
+}
+
+// newHospitalChooser builds a hospitalChooser from the hospital lookup, which must have a class field,
+// and, if catchment is not nil, from the catchment table and the geocodes that patients can have.
+// The catchment regions are matched against regions[i], the region of geoCodes[i].
+func newHospitalChooser(hospitals *Lookup, catchment []catchmentRow, geoCodes, regions []string, adultAge int, returnProb float64) (*hospitalChooser, error) {
+	c := &hospitalChooser{
+		all:        hospitals,
+		classOf:    make(map[string]string, len(hospitals.Codes)),
+		byClass:    make(map[string]*Lookup),
+		byGeoCode:  make(map[string]map[string]*Lookup),
+		adultAge:   adultAge,
+		returnProb: returnProb,
+	}
+	for i, code := range hospitals.Codes {
+		c.classOf[code] = hospitals.Class[i]
+	}
+	for _, class := range []string{classAdult, classPeds} {
+		var (
+			codes []string
+			probs []float64
+		)
+		for i, code := range hospitals.Codes {
+			if hospitals.Class[i] == class {
+				codes = append(codes, code)
+				probs = append(probs, hospitals.Probs[i])
+			}
+		}
+		if len(codes) == 0 {
+			continue
+		}
+		lookup, err := newLookup(hospitals.FieldName, codes, probs)
+		if err != nil {
+			return nil, err
+		}
+		c.byClass[class] = lookup
+	}
+	for _, row := range catchment {
+		if _, found := c.classOf[row.hospID]; !found {
+			return nil, fmt.Errorf("catchment hospital %s is not in the hospital lookup", row.hospID)
+		}
+	}
+	for g, geoCode := range geoCodes {
+		c.byGeoCode[geoCode] = make(map[string]*Lookup)
+		for _, class := range []string{classAdult, classPeds} {
+			var (
+				codes []string
+				freqs []float64
+			)
+			for _, row := range catchment {
+				if c.classOf[row.hospID] == class && identify.Match(regions[g], row.region) {
+					codes = append(codes, row.hospID)
+					freqs = append(freqs, row.freq)
+				}
+			}
+			if len(codes) == 0 {
+				continue
+			}
+			lookup, err := newLookup(hospitals.FieldName, codes, freqs)
+			if err != nil {
+				return nil, fmt.Errorf("catchment of %s: %s", geoCode, err)
+			}
+			c.byGeoCode[geoCode][class] = lookup
+		}
+	}
+	return c, nil
+}
+
+// choose returns the hospital of an admission of a patient of age living in geoCode whose previous
+// admission was to lastHospID (empty for a first admission). The patient returns to the same hospital
+// with probability returnProb if it serves the patient's age class. Otherwise, a hospital of the
+// patient's class is picked from the catchment of geoCode, or from all hospitals if there is none.
+func (c *hospitalChooser) choose(rnd *rand.Rand, age int, geoCode, lastHospID string) string {
+	class := classAdult
+	if age < c.adultAge {
+		class = classPeds
+	}
+	if lastHospID != "" && c.classOf[lastHospID] == class && rnd.Float64() < c.returnProb {
+		return lastHospID
+	}
+	if lookup := c.byGeoCode[geoCode][class]; lookup != nil {
+		return lookup.RandCode(rnd)
+	}
+	if lookup := c.byClass[class]; lookup != nil {
+		return lookup.RandCode(rnd)
+	}
+	return c.all.RandCode(rnd)
+}
+
+// loadCatchment loads a hospital catchment table from a csv file with the fields region, code and freq.
+// region is a postal code pattern (eg R3L%), or a pattern of locator attribute values if catchment_attribute
+// is set, code a hospital id and freq the relative frequency with which residents of the region are admitted
+// to the hospital.
+func loadCatchment(fileName string) ([]catchmentRow, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	csv := csv.NewReader(file)
+	// Lines beginning with "/" without preceding whitespace are ignored.
+	csv.Comment = '/'
+	csv.FieldsPerRecord = 3
+	record, err := csv.Read()
+	switch {
+	case err == io.EOF:
+		return nil, fmt.Errorf("empty csv file")
+	case err != nil:
+		return nil, err
+	}
+	if strings.TrimSpace(record[0]) != "region" || strings.TrimSpace(record[1]) != "code" || strings.TrimSpace(record[2]) != "freq" {
+		return nil, fmt.Errorf("required field names are missing. The fields must be named 'region', 'code' and 'freq'")
+	}
+	var rows []catchmentRow
+	recNum := 1
+	for {
+		record, err := csv.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recNum++
+		row := catchmentRow{region: strings.TrimSpace(record[0]), hospID: strings.TrimSpace(record[1])}
+		if row.region == "" || row.hospID == "" {
+			return nil, fmt.Errorf("missing region or code in line number %d", recNum)
+		}
+		if row.freq, err = strconv.ParseFloat(strings.TrimSpace(record[2]), 64); err != nil {
+			return nil, fmt.Errorf("invalid freq in line number %d: %s", recNum, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
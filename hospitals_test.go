@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHospitalChooser(t *testing.T) {
+	hospitals, err := newLookup("hosp_id", []string{"1", "2", "3", "4"}, []float64{1, 1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hospitals.Class = []string{"adult", "peds", "adult", "adult"}
+	catchment := []catchmentRow{
+		{region: "R3L1%", hospID: "1", freq: 1},
+		{region: "R3L2%", hospID: "3", freq: 1},
+	}
+	c, err := newHospitalChooser(hospitals, catchment, []string{"R3L1E9", "R3L2E9", "R3L3E9"}, []string{"R3L1E9", "R3L2E9", "R3L3E9"}, 18, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if got := c.choose(rnd, 10, "R3L1E9", ""); got != "2" {
+			t.Fatalf("child admitted to hospital %s, want peds hospital 2", got)
+		}
+		if got := c.choose(rnd, 40, "R3L1E9", ""); got != "1" {
+			t.Fatalf("adult from R3L1E9 admitted to hospital %s, want 1", got)
+		}
+		if got := c.choose(rnd, 40, "R3L3E9", ""); got == "2" {
+			t.Fatalf("adult from R3L3E9 admitted to peds hospital")
+		}
+	}
+	c.returnProb = 1
+	if got := c.choose(rnd, 40, "R3L2E9", "4"); got != "4" {
+		t.Errorf("readmission to hospital %s, want 4", got)
+	}
+	if got := c.choose(rnd, 10, "R3L2E9", "4"); got != "2" {
+		t.Errorf("child readmitted to adult hospital %s, want 2", got)
+	}
+	if _, err := newHospitalChooser(hospitals, []catchmentRow{{region: "%", hospID: "9", freq: 1}}, nil, nil, 18, 0); err == nil {
+		t.Errorf("catchment with unknown hospital should fail")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	roleHead = iota
+	roleSpouse
+	roleChild
+	roleOther
+)
+
+// Households holds config for generating people in households
+type Households struct {
+	SizeProbs    []float64 `json:"size_probs"`
+	CoupleProb   float64   `json:"couple_prob"`
+	MaxAgeGap    int       `json:"max_age_gap"`
+	MinParentAge int       `json:"min_parent_age"`
+	MaxParentAge int       `json:"max_parent_age"`
+	sizes        *Lookup
+}
+
+// family is a household: people sharing a family id, postal code and coverage
+type family struct {
+	id      int64
+	members []*Person
+}
+
+func (h *Households) validate() error {
+	if len(h.SizeProbs) == 0 {
+		return fmt.Errorf("households size_probs must have at least 1 probability")
+	}
+	if h.CoupleProb < 0 || h.CoupleProb > 1 {
+		return fmt.Errorf("households couple_prob must be between 0 and 1")
+	}
+	if h.MaxAgeGap == 0 {
+		h.MaxAgeGap = 5
+	}
+	if h.MinParentAge == 0 {
+		h.MinParentAge = 18
+	}
+	if h.MaxParentAge == 0 {
+		h.MaxParentAge = 45
+	}
+	if h.MaxAgeGap < 0 || h.MinParentAge < 0 || h.MaxParentAge < h.MinParentAge {
+		return fmt.Errorf("invalid households max_age_gap, min_parent_age or max_parent_age")
+	}
+	sizes := make([]string, len(h.SizeProbs))
+	for i, prob := range h.SizeProbs {
+		if prob < 0 || prob > 1 {
+			return fmt.Errorf("households size_probs must be between 0 and 1")
+		}
+		sizes[i] = strconv.Itoa(i + 1)
+	}
+	var err error
+	if h.sizes, err = newLookup("size", sizes, h.SizeProbs); err != nil {
+		return fmt.Errorf("invalid households size_probs: %s", err)
+	}
+	return nil
+}
+
+// size returns the number of people in a random household
+func (h *Households) size(rnd *rand.Rand) int {
+	size, _ := strconv.Atoi(h.sizes.RandCode(rnd))
+	return size
+}
+
+// NewHousehold generates a household of size people. The adults are at least min_parent_age years old
+// when the household joins the database. A household of 2 or more is headed by a couple of opposite sex with probability couple_prob; the other
+// members are children of the head (and spouse) born when their mother (or head) was min_parent_age to
+// max_parent_age years old. Members who cannot be such children are added as unrelated household members.
+// All members share the coverage and address history of the household; children born during coverage
+// join at birth. The household is unit u and is generated using the random numbers of rnd.
+func NewHousehold(config *Config, u *unit, size int, rnd *rand.Rand) {
+	defer u.done()
+	h := config.Households
+	year := int64(secondsInDay * daysInYear)
+	end := config.Population.databaseEndDate
+	f := &family{id: int64(u.index + 1)}
+	head := newPerson(config, u, rnd)
+	head.dob = RangeDate(rnd, config.Population.minDate, end-int64(h.MinParentAge)*year)
+	f.add(head, roleHead)
+	var spouse, mother *Person
+	if size > 1 && rnd.Float64() < h.CoupleProb {
+		spouse = newPerson(config, u, rnd)
+		spouse.sex = 1 - head.sex
+		spouse.dob = head.dob + int64(RangeInt(rnd, -h.MaxAgeGap, h.MaxAgeGap))*year
+		if spouse.dob < config.Population.minDate {
+			spouse.dob = config.Population.minDate
+		}
+		if spouse.dob > end-int64(h.MinParentAge)*year {
+			spouse.dob = end - int64(h.MinParentAge)*year
+		}
+		f.add(spouse, roleSpouse)
+	}
+	// the household joins the database when its adults are min_parent_age years old at the latest
+	for _, adult := range f.members {
+		if adult.dob+int64(h.MinParentAge)*year > head.regisDate {
+			head.regisDate = adult.dob + int64(h.MinParentAge)*year
+		}
+	}
+	if head.cancelDate < head.regisDate {
+		head.cancelDate = RangeDate(rnd, head.regisDate, end)
+	}
+	if config.Options.LocationNeeded {
+		head.addAddresses()
+	}
+	parent := head
+	if spouse != nil && spouse.sex == 1 {
+		parent = spouse
+	}
+	if parent.sex == 1 {
+		mother = parent
+	}
+	for len(f.members) < size {
+		child := newPerson(config, u, rnd)
+		minDOB := parent.dob + int64(h.MinParentAge)*year
+		maxDOB := parent.dob + int64(h.MaxParentAge)*year
+		if maxDOB >= head.cancelDate {
+			maxDOB = head.cancelDate - secondsInDay
+		}
+		if minDOB > maxDOB {
+			f.add(child, roleOther)
+			continue
+		}
+		child.dob = RangeDate(rnd, minDOB, maxDOB)
+		f.add(child, roleChild)
+	}
+	for _, p := range f.members {
+		p.share(head)
+		p.age = config.Population.ageAtEnd(p.dob)
+		p.generate()
+	}
+	if config.Options.FamilyNeeded {
+		f.saveRelationships(u, spouse, mother)
+	}
+}
+
+func (f *family) add(p *Person, role int) {
+	p.family = f
+	p.role = role
+	f.members = append(f.members, p)
+}
+
+// share gives p the coverage and address history of the household head. Members born during the
+// coverage of the household join at birth. Members other than children must have been born by then.
+func (p *Person) share(head *Person) {
+	if p == head {
+		return
+	}
+	p.regisDate, p.cancelDate = head.regisDate, head.cancelDate
+	switch {
+	case p.role == roleChild && p.dob > p.regisDate:
+		p.regisDate = p.dob
+	case p.dob > p.regisDate:
+		p.dob = p.regisDate
+	}
+	p.geoCode = head.geoCode
+	p.addresses = clipAddresses(head.addresses, p.regisDate, p.cancelDate)
+}
+
+// isBloodRelative reports whether p and q are parent and child or siblings
+func (p *Person) isBloodRelative(q *Person) bool {
+	if p.role == roleOther || q.role == roleOther {
+		return false
+	}
+	return p.role == roleChild || q.role == roleChild
+}
+
+// saveRelationships writes the linkage table rows of the family: each child is linked to its mother and
+// father, each parent to its children and the spouses to each other.
+func (f *family) saveRelationships(u *unit, spouse, mother *Person) {
+	save := func(p, relative *Person, relationship string) {
+		u.SaveFamily([]string{strconv.Itoa(int(p.id)), strconv.Itoa(int(relative.id)), relationship})
+	}
+	head := f.members[0]
+	if spouse != nil {
+		save(head, spouse, "spouse")
+		save(spouse, head, "spouse")
+	}
+	for _, child := range f.members {
+		if child.role != roleChild {
+			continue
+		}
+		for _, parent := range []*Person{head, spouse} {
+			if parent == nil {
+				continue
+			}
+			relationship := "father"
+			if parent == mother {
+				relationship = "mother"
+			}
+			save(child, parent, relationship)
+			save(parent, child, "child")
+		}
+	}
+}
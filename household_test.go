@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestHouseholdShare(t *testing.T) {
+	day := int64(secondsInDay)
+	head := &Person{regisDate: 100 * day, cancelDate: 300 * day, geoCode: "B", addresses: []address{
+		{geoCode: "A", start: 100 * day, end: 200 * day},
+		{geoCode: "B", start: 200 * day, end: 300 * day},
+	}}
+	f := &family{}
+	f.add(head, roleHead)
+	child := &Person{dob: 250 * day}
+	f.add(child, roleChild)
+	lodger := &Person{dob: 150 * day}
+	f.add(lodger, roleOther)
+	child.share(head)
+	lodger.share(head)
+	if child.regisDate != child.dob || child.cancelDate != head.cancelDate {
+		t.Errorf("child covered from %d to %d, want birth to %d", child.regisDate/day, child.cancelDate/day, head.cancelDate/day)
+	}
+	if len(child.addresses) != 1 || child.addresses[0].start != child.dob || child.geoCodeAt(child.dob) != "B" {
+		t.Errorf("child addresses = %+v, want the household address from birth", child.addresses)
+	}
+	if lodger.dob != head.regisDate || len(lodger.addresses) != 2 {
+		t.Errorf("lodger born %d with %d addresses, want born by %d with 2", lodger.dob/day, len(lodger.addresses), head.regisDate/day)
+	}
+	if !child.isBloodRelative(head) || lodger.isBloodRelative(head) || head.isBloodRelative(&Person{role: roleSpouse}) {
+		t.Errorf("wrong blood relatives")
+	}
+
+	config := &Config{Locator: &LookupDescriptor{}}
+	disease := &Disease{Name: "diabetes", PrevalenceMale: 0.1, FamilialRatio: 3, HouseholdRatio: 2}
+	for _, p := range f.members {
+		p.config = config
+	}
+	head.has = map[string]bool{"diabetes": true}
+	for _, tt := range []struct {
+		name string
+		p    *Person
+		want float64
+	}{
+		{"child", child, 0.3},
+		{"lodger", lodger, 0.2},
+		{"head", head, 0.1},
+	} {
+		if got := tt.p.prevalence(disease); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("%s prevalence = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
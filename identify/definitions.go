@@ -0,0 +1,218 @@
+package identify
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Data sources as named in criteria.csv and patterns.csv
+const (
+	SourceHosp = "HOSP"
+	SourcePhys = "PHYS"
+	SourceDpin = "DPIN"
+)
+
+// Coding systems as named in patterns.csv
+const (
+	CodingICD9  = "ICD-9"
+	CodingICD10 = "ICD-10"
+	CodingDIN   = "DIN"
+	CodingATC   = "ATC"
+)
+
+// Condition is a row of conditions.csv
+type Condition struct {
+	ID   string
+	Name string
+}
+
+// Criterion is a row of criteria.csv. A subject has the condition in a data source if they
+// have at least MinNumCases matching records at least TimeBetweenCases days apart, all within
+// TimePeriod days. TimeBetweenCases and TimePeriod are 0 when missing.
+type Criterion struct {
+	ConditionID      string
+	DataSource       string
+	MinNumCases      int
+	TimeBetweenCases int
+	TimePeriod       int
+}
+
+// Pattern is a row of patterns.csv
+type Pattern struct {
+	ConditionID  string
+	DataSource   string
+	CodingSystem string
+	Pattern      string
+}
+
+// Definitions holds the case definitions read from the identify-conditions config files.
+type Definitions struct {
+	Conditions []Condition
+	Criteria   []Criterion
+	Patterns   []Pattern
+	// maps a DIN to its ATC codes
+	RxReference map[string][]string
+}
+
+// LoadDefinitions reads conditions.csv, criteria.csv, patterns.csv and, if present, rx_reference.csv from dir.
+func LoadDefinitions(dir string) (*Definitions, error) {
+	defs := &Definitions{RxReference: make(map[string][]string)}
+	err := readCSV(filepath.Join(dir, "conditions.csv"), []string{"condition_id", "condition_name"},
+		func(line int, rec map[string]string) error {
+			defs.Conditions = append(defs.Conditions, Condition{ID: rec["condition_id"], Name: rec["condition_name"]})
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = readCSV(filepath.Join(dir, "criteria.csv"), []string{"condition_id", "data_source", "min_num_cases", "time_between_cases", "time_period"},
+		func(line int, rec map[string]string) error {
+			c := Criterion{ConditionID: rec["condition_id"], DataSource: strings.ToUpper(rec["data_source"])}
+			var err error
+			if c.MinNumCases, err = parseDays(rec["min_num_cases"]); err != nil || c.MinNumCases < 1 {
+				return fmt.Errorf("line %d: min_num_cases must be a positive integer", line)
+			}
+			if c.TimeBetweenCases, err = parseDays(rec["time_between_cases"]); err != nil {
+				return fmt.Errorf("line %d: time_between_cases: %s", line, err)
+			}
+			if c.TimePeriod, err = parseDays(rec["time_period"]); err != nil {
+				return fmt.Errorf("line %d: time_period: %s", line, err)
+			}
+			if err = validSource(c.DataSource); err != nil {
+				return fmt.Errorf("line %d: %s", line, err)
+			}
+			defs.Criteria = append(defs.Criteria, c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = readCSV(filepath.Join(dir, "patterns.csv"), []string{"condition_id", "data_source", "coding_system", "code_matching_pattern"},
+		func(line int, rec map[string]string) error {
+			p := Pattern{
+				ConditionID:  rec["condition_id"],
+				DataSource:   strings.ToUpper(rec["data_source"]),
+				CodingSystem: strings.ToUpper(rec["coding_system"]),
+				Pattern:      rec["code_matching_pattern"],
+			}
+			if err := validSource(p.DataSource); err != nil {
+				return fmt.Errorf("line %d: %s", line, err)
+			}
+			defs.Patterns = append(defs.Patterns, p)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	fileName := filepath.Join(dir, "rx_reference.csv")
+	if _, err := os.Stat(fileName); err == nil {
+		err = readCSV(fileName, []string{"atc", "din"},
+			func(line int, rec map[string]string) error {
+				if rec["atc"] != "" {
+					defs.RxReference[rec["din"]] = append(defs.RxReference[rec["din"]], strings.ToUpper(rec["atc"]))
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// criterion returns the criterion for a condition in a data source or nil if there is none.
+func (defs *Definitions) criterion(conditionID, dataSource string) *Criterion {
+	for i := range defs.Criteria {
+		if defs.Criteria[i].ConditionID == conditionID && defs.Criteria[i].DataSource == dataSource {
+			return &defs.Criteria[i]
+		}
+	}
+	return nil
+}
+
+func validSource(dataSource string) error {
+	switch dataSource {
+	case SourceHosp, SourcePhys, SourceDpin:
+		return nil
+	}
+	return fmt.Errorf("unsupported data source: %s", dataSource)
+}
+
+// parseDays parses a number of days; empty strings and "." (SAS missing) are returned as 0.
+func parseDays(s string) (int, error) {
+	if s == "" || s == "." {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
+
+// readCSV calls fn with each record of a csv file keyed by its (lower case) field names.
+// Records may have fewer or more fields than the header; missing fields are empty.
+func readCSV(fileName string, required []string, fn func(line int, rec map[string]string) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = readRecords(file, required, fn); err != nil {
+		return fmt.Errorf("%s: %s", fileName, err)
+	}
+	return nil
+}
+
+func readRecords(r io.Reader, required []string, fn func(line int, rec map[string]string) error) error {
+	csv := csv.NewReader(r)
+	csv.FieldsPerRecord = -1
+	header, err := csv.Read()
+	switch {
+	case err == io.EOF:
+		return fmt.Errorf("empty csv file")
+	case err != nil:
+		return err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	for _, name := range required {
+		found := false
+		for _, h := range header {
+			found = found || h == name
+		}
+		if !found {
+			return fmt.Errorf("required field %s is missing", name)
+		}
+	}
+	line := 1
+	rec := make(map[string]string, len(header))
+	for {
+		record, err := csv.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		for i, name := range header {
+			rec[name] = ""
+			if i < len(record) {
+				rec[name] = strings.TrimSpace(record[i])
+			}
+		}
+		if err = fn(line, rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package identify finds subjects with chronic conditions in administrative health data
+// using the case definitions of identify-conditions (conditions.csv, criteria.csv and patterns.csv).
+// It is a Go port of the identify_conditions SAS macro and reads the csv files generated by sim.
+package identify
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dateLayoutISO = "2006-01-02"
+
+// Study restricts case finding to records within a study period and to subjects
+// who reached MinAge. Zero values mean no restriction.
+type Study struct {
+	Start  time.Time
+	End    time.Time
+	MinAge int
+}
+
+// Case is a subject identified as having a condition and the date of its first occurrence.
+type Case struct {
+	SubjectID       string
+	ConditionID     string
+	FirstOccurrence time.Time
+}
+
+type subject struct {
+	id    string
+	start time.Time // start of the subject's study period
+	end   time.Time
+}
+
+type diagnosisKey struct {
+	subjectID   string
+	dataSource  string
+	conditionID string
+}
+
+// Identifier applies case definitions to a study population and its encounter records.
+type Identifier struct {
+	defs      *Definitions
+	study     Study
+	subjects  map[string]*subject
+	order     []string // subject ids of the valid population in the order loaded
+	diagnoses map[diagnosisKey]map[time.Time]bool
+}
+
+// New returns an Identifier for the given case definitions and study restrictions.
+func New(defs *Definitions, study Study) *Identifier {
+	return &Identifier{
+		defs:      defs,
+		study:     study,
+		subjects:  make(map[string]*subject),
+		diagnoses: make(map[diagnosisKey]map[time.Time]bool),
+	}
+}
+
+// LoadPopulation reads a person.csv file. Only subjects whose study period, from the latest of
+// coverage_start, the date they reached Study.MinAge and Study.Start, to the earliest of coverage_end
+// and Study.End, is not empty are retained.
+func (id *Identifier) LoadPopulation(r io.Reader) error {
+	return readRecords(r, []string{"subject_id", "birthdate", "coverage_start", "coverage_end"},
+		func(line int, rec map[string]string) error {
+			var (
+				s   = subject{id: rec["subject_id"]}
+				dob time.Time
+				err error
+			)
+			if dob, err = time.Parse(dateLayoutISO, rec["birthdate"]); err != nil {
+				return fmt.Errorf("line %d: invalid birthdate: %s", line, err)
+			}
+			if s.start, err = time.Parse(dateLayoutISO, rec["coverage_start"]); err != nil {
+				return fmt.Errorf("line %d: invalid coverage_start: %s", line, err)
+			}
+			if s.end, err = time.Parse(dateLayoutISO, rec["coverage_end"]); err != nil {
+				return fmt.Errorf("line %d: invalid coverage_end: %s", line, err)
+			}
+			if ageDate := dob.AddDate(id.study.MinAge, 0, 0); ageDate.After(s.start) {
+				s.start = ageDate
+			}
+			if !id.study.Start.IsZero() && id.study.Start.After(s.start) {
+				s.start = id.study.Start
+			}
+			if !id.study.End.IsZero() && id.study.End.Before(s.end) {
+				s.end = id.study.End
+			}
+			if s.start.After(s.end) {
+				return nil
+			}
+			if _, found := id.subjects[s.id]; found {
+				return fmt.Errorf("line %d: duplicate subject_id %s", line, s.id)
+			}
+			id.subjects[s.id] = &s
+			id.order = append(id.order, s.id)
+			return nil
+		})
+}
+
+// AddSource reads the records of a data source (HOSP, PHYS or DPIN) from a csv file with
+// the fields subject_id, service_date and code, as in hosp.csv, clinic.csv and rx.csv.
+// Records outside a subject's study period are ignored. HOSP codes are ICD-10 (dots are removed),
+// PHYS codes are ICD-9 and DPIN codes are DINs which are also matched by their ATC codes, taken from
+// the atc field if present (see the atc_needed option of sim) and from rx_reference.csv.
+func (id *Identifier) AddSource(dataSource string, r io.Reader) error {
+	dataSource = strings.ToUpper(dataSource)
+	if err := validSource(dataSource); err != nil {
+		return err
+	}
+	var patterns []Pattern
+	for _, p := range id.defs.Patterns {
+		if p.DataSource == dataSource {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	return readRecords(r, []string{"subject_id", "service_date", "code"},
+		func(line int, rec map[string]string) error {
+			s := id.subjects[rec["subject_id"]]
+			if s == nil {
+				return nil
+			}
+			date, err := time.Parse(dateLayoutISO, rec["service_date"])
+			if err != nil {
+				return fmt.Errorf("line %d: invalid service_date: %s", line, err)
+			}
+			if date.Before(s.start) || date.After(s.end) {
+				return nil
+			}
+			for _, p := range patterns {
+				if id.matches(dataSource, rec["code"], rec["atc"], p) {
+					key := diagnosisKey{subjectID: s.id, dataSource: dataSource, conditionID: p.ConditionID}
+					if id.diagnoses[key] == nil {
+						id.diagnoses[key] = make(map[time.Time]bool)
+					}
+					id.diagnoses[key][date] = true
+				}
+			}
+			return nil
+		})
+}
+
+func (id *Identifier) matches(dataSource, code, atc string, p Pattern) bool {
+	switch dataSource {
+	case SourceHosp:
+		return p.CodingSystem == CodingICD10 && Match(strings.Replace(code, ".", "", -1), p.Pattern)
+	case SourcePhys:
+		return p.CodingSystem == CodingICD9 && Match(code, p.Pattern)
+	}
+	switch p.CodingSystem {
+	case CodingDIN:
+		return Match(code, p.Pattern)
+	case CodingATC:
+		if atc != "" && Match(atc, p.Pattern) {
+			return true
+		}
+		for _, atc := range id.defs.RxReference[code] {
+			if Match(atc, p.Pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cases applies the criteria to the records added so far and returns, for each subject and condition,
+// the earliest date on which the condition was met in any data source.
+// Cases are sorted by subject_id and condition_id.
+func (id *Identifier) Cases() []Case {
+	first := make(map[[2]string]time.Time)
+	for key, dateSet := range id.diagnoses {
+		c := id.defs.criterion(key.conditionID, key.dataSource)
+		if c == nil {
+			continue
+		}
+		dates := make([]time.Time, 0, len(dateSet))
+		for date := range dateSet {
+			dates = append(dates, date)
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+		date, found := c.apply(dates)
+		if !found {
+			continue
+		}
+		k := [2]string{key.subjectID, key.conditionID}
+		if prev, ok := first[k]; !ok || date.Before(prev) {
+			first[k] = date
+		}
+	}
+	cases := make([]Case, 0, len(first))
+	for k, date := range first {
+		cases = append(cases, Case{SubjectID: k[0], ConditionID: k[1], FirstOccurrence: date})
+	}
+	sort.Slice(cases, func(i, j int) bool {
+		if cases[i].SubjectID != cases[j].SubjectID {
+			return cases[i].SubjectID < cases[j].SubjectID
+		}
+		return cases[i].ConditionID < cases[j].ConditionID
+	})
+	return cases
+}
+
+// Population returns the ids of the subjects with a non-empty study period sorted by subject_id.
+func (id *Identifier) Population() []string {
+	ids := append([]string(nil), id.order...)
+	sort.Strings(ids)
+	return ids
+}
+
+// apply returns the date of first occurrence if the sorted, distinct service dates meet the criterion.
+func (c *Criterion) apply(dates []time.Time) (time.Time, bool) {
+	// drop cases closer than TimeBetweenCases days to the previous retained case
+	if c.TimeBetweenCases > 0 {
+		kept := dates[:1]
+		for _, date := range dates[1:] {
+			if days(kept[len(kept)-1], date) >= c.TimeBetweenCases {
+				kept = append(kept, date)
+			}
+		}
+		dates = kept
+	}
+	if c.TimePeriod == 0 || c.MinNumCases == 1 {
+		if len(dates) >= c.MinNumCases {
+			return dates[0], true
+		}
+		return time.Time{}, false
+	}
+	// a time window of TimePeriod days starts at each case
+	for i, start := range dates {
+		n := 0
+		for _, date := range dates[i:] {
+			if days(start, date) >= c.TimePeriod {
+				break
+			}
+			n++
+		}
+		if n >= c.MinNumCases {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func days(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
@@ -0,0 +1,119 @@
+package identify
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const demoDir = "../identify-conditions-demo-sim"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		code    string
+		pattern string
+		want    bool
+	}{
+		{"A10BA02", "A10%", true},
+		{"A10BA02", "a10*", true},
+		{"A10BA02", "A10", false},
+		{"E119", "E11%", true},
+		{"E119", "E1_9", true},
+		{"E19", "E1_9", false},
+		{"250", "250", true},
+		{"2501", "250", false},
+		{"I050", "%05%", true},
+		{"", "%", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code+" "+tt.pattern, func(t *testing.T) {
+			if got := Match(tt.code, tt.pattern); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCriterionApply(t *testing.T) {
+	d := func(s string) time.Time {
+		date, _ := time.Parse(dateLayoutISO, s)
+		return date
+	}
+	dates := []time.Time{d("2000-01-01"), d("2000-01-10"), d("2003-01-01"), d("2003-06-01")}
+	tests := []struct {
+		name      string
+		criterion Criterion
+		want      time.Time
+		found     bool
+	}{
+		{"one case", Criterion{MinNumCases: 1}, d("2000-01-01"), true},
+		{"5 cases", Criterion{MinNumCases: 5}, time.Time{}, false},
+		{"2 cases in 30 days", Criterion{MinNumCases: 2, TimePeriod: 30}, d("2000-01-01"), true},
+		{"2 cases in 30 days, 30 days apart", Criterion{MinNumCases: 2, TimePeriod: 30, TimeBetweenCases: 30}, time.Time{}, false},
+		{"2 cases in 365 days, 30 days apart", Criterion{MinNumCases: 2, TimePeriod: 365, TimeBetweenCases: 30}, d("2003-01-01"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := tt.criterion.apply(append([]time.Time(nil), dates...))
+			if found != tt.found || !got.Equal(tt.want) {
+				t.Errorf("apply() = %v %v, want %v %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+// TestDemo reproduces the results of the SAS macro on the demo data.
+func TestDemo(t *testing.T) {
+	defs, err := LoadDefinitions(filepath.Join(demoDir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	study := Study{
+		Start:  time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2004, 12, 31, 0, 0, 0, 0, time.UTC),
+		MinAge: 40,
+	}
+	id := New(defs, study)
+	for _, f := range []struct{ source, file string }{
+		{"", "person.csv"}, {SourceHosp, "hosp.csv"}, {SourcePhys, "clinic.csv"}, {SourceDpin, "dpin.csv"},
+	} {
+		file, err := os.Open(filepath.Join(demoDir, "data", f.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.source == "" {
+			err = id.LoadPopulation(file)
+		} else {
+			err = id.AddSource(f.source, file)
+		}
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", f.file, err)
+		}
+	}
+	file, err := os.Open(filepath.Join(demoDir, "results", "conditions_long.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	want, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = want[1:]
+	got := id.Cases()
+	if len(got) != len(want) {
+		t.Fatalf("found %d cases, want %d", len(got), len(want))
+	}
+	for i, c := range got {
+		date, _ := time.Parse("02-Jan-2006", want[i][2])
+		if c.SubjectID != want[i][0] || c.ConditionID != want[i][1] || !c.FirstOccurrence.Equal(date) {
+			t.Errorf("case %d = %v, want %v", i, c, want[i])
+		}
+	}
+	if n := len(id.Population()); n != 46 {
+		t.Errorf("Population() has %d subjects, want 46", n)
+	}
+}
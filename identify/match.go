@@ -0,0 +1,38 @@
+package identify
+
+import "strings"
+
+// Match reports whether code matches pattern using SQL LIKE wildcards as in
+// patterns.csv: % (or *) matches any run of characters and _ matches a single character.
+// Matching is case insensitive.
+func Match(code, pattern string) bool {
+	return match(strings.ToUpper(code), strings.ToUpper(pattern))
+}
+
+func match(code, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%', '*':
+			pattern = strings.TrimLeft(pattern, "%*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(code); i++ {
+				if match(code[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if code == "" {
+				return false
+			}
+		default:
+			if code == "" || code[0] != pattern[0] {
+				return false
+			}
+		}
+		code, pattern = code[1:], pattern[1:]
+	}
+	return code == ""
+}
@@ -0,0 +1,75 @@
+package identify
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+)
+
+// WriteLong writes cases in long format (subject_id,condition_id,first_occurrence) as in conditions_long.csv.
+func WriteLong(w io.Writer, cases []Case) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"subject_id", "condition_id", "first_occurrence"}); err != nil {
+		return err
+	}
+	for _, c := range cases {
+		if err := cw.Write([]string{c.SubjectID, c.ConditionID, c.FirstOccurrence.Format(dateLayoutISO)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteBinary writes one row per subject in population and one 0/1 column per condition as in conditions_binary.csv.
+func WriteBinary(w io.Writer, population []string, conditions []Condition, cases []Case) error {
+	return writeWide(w, population, conditions, cases, func(c *Case) string {
+		if c == nil {
+			return "0"
+		}
+		return "1"
+	})
+}
+
+// WriteDate writes one row per subject in population and one column per condition holding the date of
+// first occurrence (empty if the subject does not have the condition) as in conditions_date.csv.
+func WriteDate(w io.Writer, population []string, conditions []Condition, cases []Case) error {
+	return writeWide(w, population, conditions, cases, func(c *Case) string {
+		if c == nil {
+			return ""
+		}
+		return c.FirstOccurrence.Format(dateLayoutISO)
+	})
+}
+
+func writeWide(w io.Writer, population []string, conditions []Condition, cases []Case, value func(*Case) string) error {
+	ids := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		ids = append(ids, c.ID)
+	}
+	sort.Strings(ids)
+	bySubject := make(map[string]map[string]*Case)
+	for i := range cases {
+		c := &cases[i]
+		if bySubject[c.SubjectID] == nil {
+			bySubject[c.SubjectID] = make(map[string]*Case)
+		}
+		bySubject[c.SubjectID][c.ConditionID] = c
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"subject_id"}, ids...)); err != nil {
+		return err
+	}
+	record := make([]string, len(ids)+1)
+	for _, subjectID := range population {
+		record[0] = subjectID
+		for i, conditionID := range ids {
+			record[i+1] = value(bySubject[subjectID][conditionID])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
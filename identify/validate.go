@@ -0,0 +1,207 @@
+package identify
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Truth is a row of truth.csv generated by sim: whether a subject actually has a condition and its onset date.
+type Truth struct {
+	SubjectID   string
+	ConditionID string
+	Onset       time.Time
+	Status      bool
+}
+
+// Accuracy holds the agreement between the cases found by a case definition and the truth for one condition.
+// OnsetError statistics are in days (first occurrence minus onset) and computed over true positives.
+type Accuracy struct {
+	ConditionID       string
+	TP, FP, FN, TN    int
+	Sensitivity       float64
+	Specificity       float64
+	PPV               float64
+	NPV               float64
+	Kappa             float64
+	MeanOnsetError    float64
+	MedianOnsetError  float64
+	MeanAbsOnsetError float64
+}
+
+// ReadTruth reads truth.csv (subject_id,disease,onset_date,status).
+func ReadTruth(r io.Reader) ([]Truth, error) {
+	var truth []Truth
+	err := readRecords(r, []string{"subject_id", "disease", "onset_date", "status"},
+		func(line int, rec map[string]string) error {
+			t := Truth{SubjectID: rec["subject_id"], ConditionID: rec["disease"], Status: rec["status"] == "1"}
+			if t.Status {
+				var err error
+				if t.Onset, err = parseDate(rec["onset_date"]); err != nil {
+					return fmt.Errorf("line %d: invalid onset_date: %s", line, err)
+				}
+			}
+			truth = append(truth, t)
+			return nil
+		})
+	return truth, err
+}
+
+// ReadLong reads cases in long format (subject_id,condition_id,first_occurrence) as written by WriteLong
+// or by the identify_conditions SAS macro.
+func ReadLong(r io.Reader) ([]Case, error) {
+	var cases []Case
+	err := readRecords(r, []string{"subject_id", "condition_id", "first_occurrence"},
+		func(line int, rec map[string]string) error {
+			date, err := parseDate(rec["first_occurrence"])
+			if err != nil {
+				return fmt.Errorf("line %d: invalid first_occurrence: %s", line, err)
+			}
+			cases = append(cases, Case{SubjectID: rec["subject_id"], ConditionID: rec["condition_id"], FirstOccurrence: date})
+			return nil
+		})
+	return cases, err
+}
+
+// ReadSubjects reads the subject_id field of a csv file such as conditions_binary.csv.
+func ReadSubjects(r io.Reader) ([]string, error) {
+	var ids []string
+	err := readRecords(r, []string{"subject_id"}, func(line int, rec map[string]string) error {
+		ids = append(ids, rec["subject_id"])
+		return nil
+	})
+	return ids, err
+}
+
+// WriteAccuracy writes validation results as csv.
+func WriteAccuracy(w io.Writer, results []Accuracy) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"condition_id", "tp", "fp", "fn", "tn", "sensitivity", "specificity", "ppv", "npv", "kappa",
+		"mean_onset_error", "median_onset_error", "mean_abs_onset_error"})
+	if err != nil {
+		return err
+	}
+	for _, a := range results {
+		record := []string{a.ConditionID, strconv.Itoa(a.TP), strconv.Itoa(a.FP), strconv.Itoa(a.FN), strconv.Itoa(a.TN)}
+		for _, v := range []float64{a.Sensitivity, a.Specificity, a.PPV, a.NPV, a.Kappa, a.MeanOnsetError, a.MedianOnsetError, a.MeanAbsOnsetError} {
+			record = append(record, formatFloat(v))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatFloat formats v with 4 decimals; NaN is written as an empty (missing) value.
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// parseDate parses ISO dates and the SAS date11. format (eg 20-JAN-1995).
+func parseDate(s string) (time.Time, error) {
+	date, err := time.Parse(dateLayoutISO, s)
+	if err == nil {
+		return date, nil
+	}
+	if date, err2 := time.Parse("02-Jan-2006", s); err2 == nil {
+		return date, nil
+	}
+	return date, err
+}
+
+// Validate compares cases to the truth for each condition in the truth. If population is not nil,
+// only the subjects in population (eg the study population of the case definition) are compared.
+// Results are sorted by condition id.
+func Validate(truth []Truth, cases []Case, population []string) []Accuracy {
+	var included map[string]bool
+	if population != nil {
+		included = make(map[string]bool, len(population))
+		for _, id := range population {
+			included[id] = true
+		}
+	}
+	found := make(map[[2]string]time.Time, len(cases))
+	for _, c := range cases {
+		found[[2]string{c.SubjectID, c.ConditionID}] = c.FirstOccurrence
+	}
+	byCondition := make(map[string]*Accuracy)
+	onsetErrors := make(map[string][]float64)
+	for _, t := range truth {
+		if included != nil && !included[t.SubjectID] {
+			continue
+		}
+		a := byCondition[t.ConditionID]
+		if a == nil {
+			a = &Accuracy{ConditionID: t.ConditionID}
+			byCondition[t.ConditionID] = a
+		}
+		date, isCase := found[[2]string{t.SubjectID, t.ConditionID}]
+		switch {
+		case t.Status && isCase:
+			a.TP++
+			onsetErrors[t.ConditionID] = append(onsetErrors[t.ConditionID], date.Sub(t.Onset).Hours()/24)
+		case t.Status:
+			a.FN++
+		case isCase:
+			a.FP++
+		default:
+			a.TN++
+		}
+	}
+	results := make([]Accuracy, 0, len(byCondition))
+	for id, a := range byCondition {
+		a.compute(onsetErrors[id])
+		results = append(results, *a)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ConditionID < results[j].ConditionID })
+	return results
+}
+
+func (a *Accuracy) compute(onsetErrors []float64) {
+	tp, fp, fn, tn := float64(a.TP), float64(a.FP), float64(a.FN), float64(a.TN)
+	n := tp + fp + fn + tn
+	a.Sensitivity = ratio(tp, tp+fn)
+	a.Specificity = ratio(tn, tn+fp)
+	a.PPV = ratio(tp, tp+fp)
+	a.NPV = ratio(tn, tn+fn)
+	a.Kappa = math.NaN()
+	if n > 0 {
+		po := (tp + tn) / n
+		pe := ((tp+fp)*(tp+fn) + (fn+tn)*(fp+tn)) / (n * n)
+		a.Kappa = ratio(po-pe, 1-pe)
+	}
+	a.MeanOnsetError, a.MedianOnsetError, a.MeanAbsOnsetError = math.NaN(), math.NaN(), math.NaN()
+	if len(onsetErrors) == 0 {
+		return
+	}
+	sort.Float64s(onsetErrors)
+	sum, sumAbs := 0.0, 0.0
+	for _, e := range onsetErrors {
+		sum += e
+		sumAbs += math.Abs(e)
+	}
+	k := len(onsetErrors)
+	a.MeanOnsetError = sum / float64(k)
+	a.MeanAbsOnsetError = sumAbs / float64(k)
+	a.MedianOnsetError = onsetErrors[k/2]
+	if k%2 == 0 {
+		a.MedianOnsetError = (onsetErrors[k/2-1] + onsetErrors[k/2]) / 2
+	}
+}
+
+// ratio returns num/den or NaN if den is 0
+func ratio(num, den float64) float64 {
+	if den == 0 {
+		return math.NaN()
+	}
+	return num / den
+}
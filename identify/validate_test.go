@@ -0,0 +1,53 @@
+package identify
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	truth, err := ReadTruth(strings.NewReader(`subject_id,disease,onset_date,status
+1,chron_diab,2000-01-01,1
+2,chron_diab,2000-01-01,1
+3,chron_diab,,0
+4,chron_diab,,0
+5,chron_diab,,0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := ReadLong(strings.NewReader(`subject_id,condition_id,first_occurrence
+1,chron_diab,11-JAN-2000
+3,chron_diab,2001-01-01
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := Validate(truth, cases, nil)
+	if len(results) != 1 {
+		t.Fatalf("got %d conditions, want 1", len(results))
+	}
+	a := results[0]
+	if a.TP != 1 || a.FN != 1 || a.FP != 1 || a.TN != 2 {
+		t.Errorf("got TP %d FN %d FP %d TN %d, want 1 1 1 2", a.TP, a.FN, a.FP, a.TN)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"sensitivity", a.Sensitivity, 0.5},
+		{"specificity", a.Specificity, 2.0 / 3},
+		{"PPV", a.PPV, 0.5},
+		{"NPV", a.NPV, 2.0 / 3},
+		{"kappa", a.Kappa, 1.0 / 6},
+		{"mean onset error", a.MeanOnsetError, 10},
+	} {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if results = Validate(truth, cases, []string{"1", "2"}); results[0].TN != 0 || results[0].FP != 0 {
+		t.Errorf("population restriction not applied: %+v", results[0])
+	}
+}
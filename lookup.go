@@ -3,43 +3,52 @@ package main
 import (
 	"encoding/csv"
 	"fmt"
-	"github/drgo/alias"
 	"io"
+	"math"
+	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/drgo/sim/rng"
 )
 
 type Lookup struct {
-	FieldName string
-	Codes     []string
-	Class     []string
-	Probs     []float64
-	alias     *alias.Alias
+	FieldName  string
+	Codes      []string
+	Class      []string
+	Probs      []float64
+	Attributes map[string][]string // values of extra fields, eg region, by field name
+	sampler    rng.Sampler
+	index      map[string]int
 }
 
+// LoadLookup loads codes and their weights from a csv file with a code field and a freq or prob field,
+// in any order. Weights are counts or probabilities and are normalised to sum to 1. Other fields are
+// attributes of the codes, eg class or region.
 func LoadLookup(fileName, fieldName string, mustClass bool) (*Lookup, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	lookup := &Lookup{FieldName: fieldName}
 	csv := csv.NewReader(file)
 	// Lines beginning with "/" without preceding whitespace are ignored.
 	csv.Comment = '/'
-	csv.FieldsPerRecord = 2
-	if mustClass {
-		csv.FieldsPerRecord = 3
-	}
 	csv.ReuseRecord = true // for performance
-	hasClass := false
-	if hasClass, err = validateHeader(csv); err != nil {
+	header, err := validateHeader(csv)
+	if err != nil {
 		return nil, err
 	}
-	if mustClass && !hasClass {
+	if mustClass && !header.hasAttribute("class") {
 		return nil, fmt.Errorf("class field is missing")
 	}
+	var (
+		codes   []string
+		weights []float64
+	)
+	attributes := make(map[string][]string, len(header.attributes))
+	lines := make(map[string]int) // line number of each code
 	recNum := 1
 readLoop:
 	for {
@@ -51,47 +60,146 @@ readLoop:
 			return nil, err
 		}
 		recNum++
-		if strings.TrimSpace(record[0]) == "" {
+		code := strings.TrimSpace(record[header.code])
+		if code == "" {
 			return nil, fmt.Errorf("missing code in line number %d", recNum)
 		}
-		lookup.Codes = append(lookup.Codes, record[0])
-		if strings.TrimSpace(record[1]) == "" {
-			return nil, fmt.Errorf("missing prob in line number %d", recNum)
+		if line, found := lines[code]; found {
+			return nil, fmt.Errorf("duplicate code %s in line number %d, first seen in line number %d", code, recNum, line)
+		}
+		lines[code] = recNum
+		value := strings.TrimSpace(record[header.weight])
+		if value == "" {
+			return nil, fmt.Errorf("missing %s in line number %d", header.weightName, recNum)
 		}
-		prob, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		weight, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid probability in line number %d: %s", recNum, err)
+			return nil, fmt.Errorf("invalid %s in line number %d: %s", header.weightName, recNum, err)
 		}
-		lookup.Probs = append(lookup.Probs, prob)
-		if hasClass {
-			lookup.Class = append(lookup.Class, record[2])
+		if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("invalid %s in line number %d: must be a number >= 0, got %s", header.weightName, recNum, value)
 		}
+		codes = append(codes, code)
+		weights = append(weights, weight)
+		for i, name := range header.attributes {
+			attributes[name] = append(attributes[name], strings.TrimSpace(record[header.attributeCols[i]]))
+		}
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no codes found")
 	}
-	lookup.alias, err = alias.New(lookup.Probs)
+	lookup, err := newLookup(fieldName, codes, weights)
 	if err != nil {
+		return nil, fmt.Errorf("invalid %s values: %s", header.weightName, err)
+	}
+	lookup.Attributes = attributes
+	lookup.Class = attributes["class"]
+	return lookup, nil
+}
+
+// newLookup builds a lookup from codes and their relative weights.
+// Weights need not sum to 1 but must be non-negative and not all zero.
+func newLookup(fieldName string, codes []string, weights []float64) (*Lookup, error) {
+	sum := 0.0
+	for _, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("weights must not be negative")
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("sum of weights must be > 0")
+	}
+	lookup := &Lookup{FieldName: fieldName, Codes: codes, Probs: make([]float64, len(weights))}
+	for i, w := range weights {
+		lookup.Probs[i] = w / sum
+	}
+	lookup.index = make(map[string]int, len(codes))
+	for i, code := range codes {
+		lookup.index[code] = i
+	}
+	var err error
+	if lookup.sampler, err = rng.NewAlias(weights, nil); err != nil {
 		return nil, err
 	}
 	return lookup, nil
 }
 
-// RandCode returns a randomly selected code
-func (l *Lookup) RandCode() string {
-	return l.Codes[l.alias.Draw()]
+// Attribute returns the value of field name for code or an empty string if there is no such code or field
+func (l *Lookup) Attribute(code, name string) string {
+	i, found := l.index[code]
+	if !found || l.Attributes[name] == nil {
+		return ""
+	}
+	return l.Attributes[name][i]
+}
+
+// HasAttribute reports whether the lookup has a field name
+func (l *Lookup) HasAttribute(name string) bool {
+	_, found := l.Attributes[name]
+	return found
+}
+
+// RandCode returns a code selected using the random numbers of rnd
+func (l *Lookup) RandCode(rnd *rand.Rand) string {
+	return l.Codes[l.sampler.SampleRand(rnd)]
+}
+
+// lookupHeader holds the positions of the fields of a lookup csv file
+type lookupHeader struct {
+	code          int
+	weight        int
+	weightName    string // freq or prob
+	attributes    []string
+	attributeCols []int
 }
 
-func validateHeader(csv *csv.Reader) (bool, error) {
+func (h *lookupHeader) hasAttribute(name string) bool {
+	for _, a := range h.attributes {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeader reads the header and finds the code and weight (freq or prob) fields and the attribute
+// fields. Field names are trimmed, code, freq and prob are case-insensitive and a UTF-8 byte order
+// mark is ignored.
+func validateHeader(csv *csv.Reader) (*lookupHeader, error) {
 	record, err := csv.Read()
 	switch {
 	case err == io.EOF:
-		return false, fmt.Errorf("empty csv file")
+		return nil, fmt.Errorf("empty csv file")
 	case err != nil:
-		return false, err
+		return nil, err
 	}
-	if record[0] != "code" && record[1] != "prob" {
-		return false, fmt.Errorf("required field names are missing. The first two fields must be named 'code' and 'prob'")
+	h := &lookupHeader{code: -1, weight: -1}
+	seen := make(map[string]bool, len(record))
+	for i, name := range record {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate field name %s in header", name)
+		}
+		seen[name] = true
+		switch strings.ToLower(name) {
+		case "code":
+			h.code = i
+		case "freq", "prob":
+			if h.weight >= 0 {
+				return nil, fmt.Errorf("header must have either a freq or a prob field, not both")
+			}
+			h.weight, h.weightName = i, strings.ToLower(name)
+		default:
+			h.attributes = append(h.attributes, name)
+			h.attributeCols = append(h.attributeCols, i)
+		}
 	}
-	if len(record) > 2 && record[2] == "class" {
-		return true, nil
+	if h.code < 0 || h.weight < 0 {
+		return nil, fmt.Errorf("required field names are missing. The fields must include 'code' and 'freq' (or 'prob')")
 	}
-	return false, nil
+	return h, nil
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drgo/sim/stattest"
+)
+
+func TestLoadLookupAttributes(t *testing.T) {
+	lookup, err := LoadLookup("./postal-codes-lookup.csv", "postal_code", false)
+	if err != nil {
+		t.Fatalf("LoadLookup() error = %v", err)
+	}
+	if !lookup.HasAttribute("urban_rural") || lookup.HasAttribute("class") {
+		t.Errorf("got attributes %v", lookup.Attributes)
+	}
+	if got := lookup.Attribute("R3L0E9", "health_authority"); got != "Southern Health" {
+		t.Errorf("Attribute() = %q, want Southern Health", got)
+	}
+	if got := lookup.Attribute("X0X0X0", "region"); got != "" {
+		t.Errorf("Attribute() of unknown code = %q, want empty", got)
+	}
+	if _, err := LoadLookup("./postal-codes-lookup.csv", "postal_code", true); err == nil {
+		t.Errorf("LoadLookup() without class field should fail if class is required")
+	}
+}
+
+func TestLoadLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    map[string]float64 // probabilities by code
+		wantErr string
+	}{
+		{"freq counts", "code,freq\nA,70\nB,30\n", map[string]float64{"A": 0.7, "B": 0.3}, ""},
+		{"bom and spaces", "\ufeffcode, prob\n A , 0.25\nB, 0.75\n", map[string]float64{"A": 0.25, "B": 0.75}, ""},
+		{"any order", "region, PROB, Code\nnorth,0.2,A\nsouth,0.8,B\n", map[string]float64{"A": 0.2, "B": 0.8}, ""},
+		{"missing prob field", "code,weight\nA,1\n", nil, "required field names are missing"},
+		{"missing code field", "id,prob\nA,1\n", nil, "required field names are missing"},
+		{"freq and prob", "code,freq,prob\nA,1,1\n", nil, "either a freq or a prob"},
+		{"duplicate code", "code,freq\nA,1\nB,1\nA,2\n", nil, "duplicate code A in line number 4, first seen in line number 2"},
+		{"missing weight", "code,freq\nA,1\nB,\n", nil, "missing freq in line number 3"},
+		{"negative weight", "code,prob\nA,-1\n", nil, "invalid prob in line number 2"},
+		{"all zero", "code,freq\nA,0\n", nil, "sum of weights must be > 0"},
+		{"no codes", "code,freq\n", nil, "no codes found"},
+	}
+	dir, err := ioutil.TempDir("", "lookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(dir, fmt.Sprintf("lookup%d.csv", i))
+			if err := ioutil.WriteFile(fileName, []byte(tt.csv), 0644); err != nil {
+				t.Fatal(err)
+			}
+			lookup, err := LoadLookup(fileName, "code", false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadLookup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadLookup() error = %v", err)
+			}
+			for code, prob := range tt.want {
+				if got := lookup.Probs[lookup.index[code]]; math.Abs(got-prob) > 1e-9 {
+					t.Errorf("prob of %s = %v, want %v", code, got, prob)
+				}
+			}
+			counts := make([]int, len(lookup.Codes))
+			rnd := rand.New(rand.NewSource(1))
+			for j := 0; j < 20000; j++ {
+				counts[lookup.index[lookup.RandCode(rnd)]]++
+			}
+			stattest.CheckCounts(t, counts, lookup.Probs, stattest.Alpha)
+		})
+	}
+}
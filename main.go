@@ -5,44 +5,106 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 var (
-	configFileName = "./config.json"
-	bufferSize     = 100
+	bufferSize = 100
 )
 
 func main() {
-	done := make(chan struct{}) //main receives done signal on this chan
-	config, err := LoadConfig(configFileName)
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	generate(defaultConfigFile())
+}
+
+// generate generates data using the configuration in configFile
+func generate(configFile string) {
+	config, err := LoadConfig(configFile)
 	if err != nil {
 		log.Fatalln("error loading configuration file:", err)
 	}
+	if err = run(config, "."); err != nil {
+		log.Fatalln("error generating data:", err)
+	}
+}
 
-	go writer("person", config, done)
-	go writer("hosp", config, done)
-	go writer("clinic", config, done)
-	go writer("rx", config, done)
-	for i := 0; i < config.N; i++ {
-		config.dispatcher.wg.Add(1)
-		go NewPerson(config)
-	}
-	config.dispatcher.wg.Wait()
-	config.dispatcher.closeAll()
+// run generates data using config and writes the csv files and their manifest to dir. If the data
+// cannot be generated, eg when subject ids run out, the csv files are incomplete and there is no
+// manifest.
+func run(config *Config, dir string) error {
+	start := time.Now()
+	done := make(chan struct{}) //main receives done signal on this chan
+	if !config.quiet {
+		config.report = newRunReport(config)
+	}
+	for _, category := range config.outputs {
+		go writer(category, dir, config, done)
+	}
+	// each person, household or entrant is a unit with its own random stream, so the random numbers
+	// it uses do not depend on the order in which goroutines run
+	d := config.dispatcher
+	go d.dispatch()
+	switch {
+	case config.Demography != nil:
+		entries := config.Demography.entries(config.stream(streamEntries), config.N, config.Population.databaseStartDate, config.Population.databaseEndDate)
+		for i, regisDate := range entries {
+			go NewEntrant(config, d.newUnit(i), regisDate, config.stream(streamUnits, uint64(i)))
+		}
+	case config.Households != nil:
+		rnd := config.stream(streamEntries)
+		for i, n := 0, 0; n < config.N; i++ {
+			size := config.Households.size(rnd)
+			if size > config.N-n {
+				size = config.N - n
+			}
+			go NewHousehold(config, d.newUnit(i), size, config.stream(streamUnits, uint64(i)))
+			n += size
+		}
+	default:
+		for i := 0; i < config.N; i++ {
+			go NewPerson(config, d.newUnit(i), config.stream(streamUnits, uint64(i)))
+		}
+	}
+	d.wg.Wait()
+	close(d.units)
 
-	for i := 0; i < 4; i++ {
+	for range config.outputs {
 		<-done //wait for all writers to quit
 	}
+	if d.err != nil {
+		return d.err
+	}
+	manifest, err := newManifest(config, dir, start, time.Now())
+	if err == nil {
+		err = manifest.save(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing the manifest: %s", err)
+	}
+	if config.report != nil {
+		if err := config.report.report().save(dir); err != nil {
+			return fmt.Errorf("error writing the run report: %s", err)
+		}
+	}
+	return nil
 }
 
-func writer(category string, config *Config, done chan struct{}) {
-	f, err := os.Create(category + ".csv")
+func writer(category, dir string, config *Config, done chan struct{}) {
+	f, err := os.Create(filepath.Join(dir, category+".csv"))
 	if err != nil {
 		log.Fatalln("error writing to file:", err)
 	}
 	defer f.Close()
-	log.Println("creating file:", category)
+	if !config.quiet {
+		log.Println("creating file:", category)
+	}
 	var fieldNames []string
 	fieldNames = strings.Split(config.fieldNames[category], ",")
 	qu, err := config.dispatcher.getQbyId(category)
@@ -58,11 +120,16 @@ func writer(category string, config *Config, done chan struct{}) {
 		if err := w.Write(record); err != nil {
 			log.Fatalln("error writing record to csv: ", err)
 		}
+		if config.report != nil {
+			config.report.add(category, record)
+		}
 	}
 	w.Flush()
 	if err := w.Error(); err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("done writing to:", category)
+	if !config.quiet {
+		fmt.Println("done writing to:", category)
+	}
 	done <- struct{}{}
 }
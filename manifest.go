@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// version and commit identify the build of sim; set them with
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=abc123". Without -ldflags, commit is read
+// from the version control information that go build stamps in the binary.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// Manifest records how the files of a run were made, written to manifest.json
+type Manifest struct {
+	SimVersion string          `json:"sim_version"`
+	GitCommit  string          `json:"git_commit"`
+	Seed       int             `json:"seed"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	Files      []*ManifestFile `json:"files"`
+	Config     *Config         `json:"config"` // the configuration after includes, migration and defaults
+}
+
+// ManifestFile describes an output file
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Rows   int    `json:"rows"` // excluding the header
+	SHA256 string `json:"sha256"`
+}
+
+// gitCommit returns the commit sim was built from, with a -dirty suffix if the working tree had
+// uncommitted changes, or "" if unknown
+func gitCommit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// newManifest returns the manifest of the csv files written to dir by a run of config
+func newManifest(config *Config, dir string, start, end time.Time) (*Manifest, error) {
+	m := &Manifest{SimVersion: version, GitCommit: gitCommit(), Seed: config.Seed,
+		StartTime: start.UTC(), EndTime: end.UTC(), Config: config}
+	for _, category := range config.outputs {
+		file, err := describeFile(filepath.Join(dir, category+".csv"))
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, file)
+	}
+	return m, nil
+}
+
+// describeFile returns the number of rows and the SHA-256 of a csv file
+func describeFile(fileName string) (*ManifestFile, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	r := csv.NewReader(io.TeeReader(f, hash))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	file := &ManifestFile{Name: filepath.Base(fileName)}
+	for {
+		if _, err = r.Read(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		file.Rows++
+	}
+	if file.Rows > 0 {
+		file.Rows-- // the header
+	}
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return file, nil
+}
+
+// save writes the manifest to manifest.json in dir
+func (m *Manifest) save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644)
+}
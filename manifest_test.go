@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDescribeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		content string
+		rows    int
+		sha256  string
+	}{
+		{"", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"a,b\n", 0, "5be08c9684a1d25efcee09318204824278b08bbfb4aef973ffefd0b9d7478313"},
+		{"a,b\n1,\"x\ny\"\n2,z\n", 2, ""},
+	}
+	for _, tt := range tests {
+		fileName := filepath.Join(dir, "t.csv")
+		if err := ioutil.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := describeFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Rows != tt.rows || tt.sha256 != "" && file.SHA256 != tt.sha256 {
+			t.Errorf("describeFile(%q) = %d rows, sha256 %s, want %d rows, sha256 %s", tt.content, file.Rows, file.SHA256, tt.rows, tt.sha256)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config, err := LoadConfig("./config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.N, config.quiet = 20, true
+	if err = run(config, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Manifest
+		Config json.RawMessage `json:"config"`
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Seed != config.Seed || manifest.EndTime.Before(manifest.StartTime) || len(manifest.Files) != len(config.outputs) {
+		t.Errorf("manifest = %+v", manifest.Manifest)
+	}
+	if f := manifest.Files[0]; f.Name != "person.csv" || f.Rows != 20 || len(f.SHA256) != 64 {
+		t.Errorf("person.csv = %+v, want 20 rows", f)
+	}
+	regenerated, err := DecodeConfig(bytes.NewReader(manifest.Config))
+	if err != nil {
+		t.Fatalf("the configuration of the manifest cannot be used to regenerate the data: %s", err)
+	}
+	if regenerated.N != 20 || len(regenerated.Diseases) != len(config.Diseases) || regenerated.Population.DatabaseEndDate != config.Population.DatabaseEndDate {
+		t.Errorf("the configuration of the manifest is not the one used")
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"math/rand"
 	"strconv"
-	"time"
+	"sync/atomic"
 )
 
 const (
@@ -18,57 +18,91 @@ const (
 	kindClinic
 )
 
-var (
-	today     = time.Now()
-	todayUnix = today.Unix()
-)
-
 // Person generates a person data
 type Person struct {
-	config     *Config
-	dispatcher *Dispatcher
-	id         int64
-	sex        int
-	age        int
-	dob        int64
-	dod        int64
-	regisDate  int64
-	cancelDate int64
-	visits     []*Visit
-	geoCode    string
+	config      *Config
+	unit        *unit // buffers the person's records
+	id          int64
+	sex         int
+	age         int
+	dob         int64
+	dod         int64
+	regisDate   int64
+	cancelDate  int64
+	visits      []*Visit
+	geoCode     string
+	addresses   []address
+	gp          *Provider
+	specialists map[string]*Provider // specialist by specialty
+	family      *family
+	role        int
+	has         map[string]bool // diseases the person has
+	rnd         *rand.Rand      // the person's random stream
+}
+
+// NewPerson generates a person, the only member of unit u, using the random numbers of rnd
+func NewPerson(config *Config, u *unit, rnd *rand.Rand) *Person {
+	defer u.done()
+	p := newPerson(config, u, rnd)
+	p.generate()
+	return p
 }
 
-func NewPerson(config *Config) *Person {
+// newPerson returns a person with random sex, birthdate and coverage. Sex and birthdate are drawn
+// from the population pyramid if there is one.
+func newPerson(config *Config, u *unit, rnd *rand.Rand) *Person {
 	p := Person{
-		config:     config,
-		dispatcher: config.dispatcher, // for convenience
-		id:         config.dispatcher.getLastID(),
-		sex:        RangeInt(0, 1), //0 male 1 female
-		dob:        RangeDate(config.Population.minDate, todayUnix),
-		visits:     []*Visit{},
-	}
-	defer p.dispatcher.wg.Done()
-	dob := toTime(p.dob)
-	p.age = today.Year() - dob.Year()
-	if rand.Float64() < config.Population.MigrantProb {
-		p.regisDate = RangeDate(config.Population.databaseStartDate, todayUnix)
+		config: config,
+		unit:   u,
+		id:     u.newID(),
+		sex:    RangeInt(rnd, 0, 1), //0 male 1 female
+		dob:    RangeDate(rnd, config.Population.minDate, config.Population.databaseEndDate),
+		visits: []*Visit{},
+		rnd:    rnd,
+	}
+	if config.Population.pyramid != nil {
+		p.sex, p.dob = config.Population.pyramid.sample(rnd, config.Population.pyramidDate)
+		if p.dob < config.Population.minDate {
+			p.dob = config.Population.minDate
+		}
+		if p.dob > config.Population.databaseEndDate {
+			p.dob = config.Population.databaseEndDate
+		}
+	}
+	p.age = config.Population.ageAtEnd(p.dob)
+	if rnd.Float64() < config.Population.MigrantProb {
+		p.regisDate = RangeDate(rnd, config.Population.databaseStartDate, config.Population.databaseEndDate)
 	} else {
 		p.regisDate = config.Population.databaseStartDate
 	}
-	if p.dob > p.regisDate {
+	switch {
+	case p.dob > p.regisDate && config.Population.pyramid != nil:
+		// born after the database start: joins the database at birth
+		p.regisDate = p.dob
+	case p.dob > p.regisDate:
 		p.dob = p.regisDate
 	}
-	if rand.Float64() < config.Population.CancelProb {
-		p.cancelDate = RangeDate(p.regisDate, todayUnix)
+	if rnd.Float64() < config.Population.CancelProb {
+		p.cancelDate = RangeDate(rnd, p.regisDate, config.Population.databaseEndDate)
 	} else {
-		p.cancelDate = todayUnix
+		p.cancelDate = config.Population.databaseEndDate
 	}
-	if config.Options.LocationNeeded {
-		p.geoCode = config.Locator.lookup.RandCode()
+	return &p
+}
+
+// generate generates the person's address history, unless it is shared with their household,
+// saves the person and generates their encounters
+func (p *Person) generate() {
+	if p.config.Options.LocationNeeded && p.addresses == nil {
+		p.addAddresses()
+	}
+	p.unit.SavePerson(p.toStrings())
+	if p.config.Options.AddressHistoryNeeded {
+		for _, a := range p.addresses {
+			p.unit.SaveAddress(a.toStrings(p.id))
+		}
 	}
-	p.dispatcher.SavePerson(p.toStrings())
 	p.addVisits()
-	return &p
 }
 
 func (p *Person) toStrings() []string {
@@ -81,37 +115,123 @@ func (p *Person) toStrings() []string {
 	a = append(a, toTime(p.cancelDate).Format(dateLayoutISO))
 	if p.config.Options.LocationNeeded {
 		a = append(a, p.geoCode)
+		for _, name := range p.config.Locator.Attributes {
+			a = append(a, p.config.Locator.lookup.Attribute(p.geoCode, name))
+		}
+	}
+	if p.config.Options.FamilyNeeded {
+		if p.family == nil {
+			a = append(a, "")
+		} else {
+			a = append(a, strconv.Itoa(int(p.family.id)))
+		}
+	}
+	if p.config.Demography != nil {
+		if p.dod == 0 {
+			a = append(a, "")
+		} else {
+			a = append(a, toTime(p.dod).Format(dateLayoutISO))
+		}
 	}
 	return a
 }
 
 func (p *Person) addVisits() {
 	for _, disease := range p.config.Diseases {
-		hadIt := p.sex == 0 && rand.Float64() < disease.PrevalenceMale ||
-			p.sex == 1 && rand.Float64() < disease.PrevalenceFemale
+		hadIt := p.rnd.Float64() < p.prevalence(disease)
 		if !hadIt {
+			if p.config.Options.TruthNeeded {
+				p.unit.SaveTruth([]string{strconv.Itoa(int(p.id)), disease.ConditionID, "", "0"})
+			}
 			continue
 		}
-		incidenceDate := RangeDate(p.regisDate, p.cancelDate)
+		if p.has == nil {
+			p.has = make(map[string]bool)
+		}
+		p.has[disease.Name] = true
+		atomic.AddInt64(&disease.cases[p.sex], 1)
+		incidenceDate := RangeDate(p.rnd, p.regisDate, p.cancelDate)
+		if p.config.Options.TruthNeeded {
+			p.unit.SaveTruth([]string{strconv.Itoa(int(p.id)), disease.ConditionID, toTime(incidenceDate).Format(dateLayoutISO), "1"})
+		}
 		fup := (p.cancelDate - incidenceDate) / secondsInDay / daysInYear
 
 		// estimate # of hospitalizations
-		n := int64(Normal(disease.HospitalRate.Mean, disease.HospitalRate.SD)) * fup
+		n := int64(disease.HospitalRate.Rand(p.rnd)) * fup
 		for i := int64(0); i < n; i++ {
-			p.dispatcher.SaveHosp(p.newVisit(kindHospital, disease, incidenceDate).toStrings())
+			p.visits = append(p.visits, p.newVisit(kindHospital, disease, incidenceDate))
 		}
 		// estimate # of clinic encounters
-		n = int64(Normal(disease.ClinicRate.Mean, disease.ClinicRate.SD)) * fup
+		n = int64(disease.ClinicRate.Rand(p.rnd)) * fup
 		for i := int64(0); i < n; i++ {
-			p.dispatcher.SaveClinic(p.newVisit(kindClinic, disease, incidenceDate).toStrings())
+			p.unit.SaveClinic(p.newVisit(kindClinic, disease, incidenceDate).toStrings())
 		}
 		// estimate # of Rxs filled
-		n = int64(Normal(disease.RxRate.Mean, disease.RxRate.SD)) * fup
+		n = int64(disease.RxRate.Rand(p.rnd)) * fup
 		for i := int64(0); i < n; i++ {
 			rx := p.newRx(disease, incidenceDate)
 			for _, drug := range rx.Drugs {
-				p.dispatcher.SaveRx(drug.toStrings())
+				p.unit.SaveRx(drug.toStrings())
 			}
 		}
 	}
+	p.scheduleStays()
+	lastHospID := ""
+	for _, v := range p.visits {
+		if p.config.Options.LocationNeeded {
+			v.geoCode = p.geoCodeAt(v.startDate)
+		}
+		if p.config.Options.HospLocationNeeded {
+			v.hospID = p.config.Hospitalization.chooser.choose(p.rnd, ageAt(p.dob, v.startDate), v.geoCode, lastHospID)
+			lastHospID = v.hospID
+		}
+		p.unit.SaveHosp(v.toStrings())
+	}
+}
+
+// prevalence returns the prevalence of disease for the person's sex multiplied by the prevalence ratios
+// that apply to the person's location and, if a household member generated before the person has the
+// disease, by the familial ratio for blood relatives or the household ratio for other members
+func (p *Person) prevalence(disease *Disease) float64 {
+	prev := disease.PrevalenceMale
+	if p.sex == 1 {
+		prev = disease.PrevalenceFemale
+	}
+	for _, pr := range disease.PrevalenceRatios {
+		if p.config.Locator.lookup.Attribute(p.geoCode, pr.Attribute) == pr.Value {
+			prev *= pr.Ratio
+		}
+	}
+	if p.family == nil {
+		return prev
+	}
+	ratio := 1.0
+	for _, q := range p.family.members {
+		if q == p || !q.has[disease.Name] {
+			continue
+		}
+		if p.isBloodRelative(q) && disease.FamilialRatio > 0 {
+			ratio = disease.FamilialRatio
+			break
+		}
+		if disease.HouseholdRatio > 0 {
+			ratio = disease.HouseholdRatio
+		}
+	}
+	return prev * ratio
+}
+
+// ageAtEnd returns the age of a person born on dob in the year of the database end date
+func (p *Population) ageAtEnd(dob int64) int {
+	return toTime(p.databaseEndDate).Year() - toTime(dob).Year()
+}
+
+// ageAt returns the age in completed years on date
+func ageAt(dob, date int64) int {
+	birth, on := toTime(dob), toTime(date)
+	age := on.Year() - birth.Year()
+	if on.Month() < birth.Month() || on.Month() == birth.Month() && on.Day() < birth.Day() {
+		age--
+	}
+	return age
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Providers holds config for the pool of physicians who bill clinic encounters
+type Providers struct {
+	N           int          `json:"n"`
+	FirstID     int          `json:"first_id"`
+	Specialties []*Specialty `json:"specialties"`
+	GPSpecialty string       `json:"gp_specialty"`
+	pool        []*Provider
+	bySpecialty map[string][]*Provider
+	gpByGeoCode map[string][]*Provider
+}
+
+// Specialty is a provider specialty and its share of the provider pool
+type Specialty struct {
+	Name string  `json:"name"`
+	Prob float64 `json:"prob"`
+}
+
+// Provider is a physician in the provider pool
+type Provider struct {
+	id        string
+	specialty string
+	geoCode   string
+}
+
+// generate validates the providers config and generates the provider pool. Each specialty gets at least
+// one provider; the remaining providers are assigned specialties by their probabilities. If locator is
+// not nil, providers are given a random location. Random numbers are drawn from rnd.
+func (ps *Providers) generate(locator *Lookup, rnd *rand.Rand) error {
+	if strings.TrimSpace(ps.GPSpecialty) == "" {
+		ps.GPSpecialty = "GP"
+	}
+	if ps.FirstID == 0 {
+		ps.FirstID = 10_000
+	}
+	if ps.N < len(ps.Specialties) {
+		return fmt.Errorf("providers n must be at least the number of specialties (%d)", len(ps.Specialties))
+	}
+	names := make([]string, len(ps.Specialties))
+	probs := make([]float64, len(ps.Specialties))
+	hasGP := false
+	for i, s := range ps.Specialties {
+		if s.Prob < 0 || s.Prob > 1 {
+			return fmt.Errorf("probability of specialty %s must be between 0 and 1", s.Name)
+		}
+		names[i], probs[i] = s.Name, s.Prob
+		hasGP = hasGP || s.Name == ps.GPSpecialty
+	}
+	if !hasGP {
+		return fmt.Errorf("providers specialties must include the gp_specialty %s", ps.GPSpecialty)
+	}
+	specialties, err := newLookup("specialty", names, probs)
+	if err != nil {
+		return fmt.Errorf("invalid specialty probabilities: %s", err)
+	}
+	ps.pool = make([]*Provider, 0, ps.N)
+	ps.bySpecialty = make(map[string][]*Provider)
+	ps.gpByGeoCode = make(map[string][]*Provider)
+	for i := 0; i < ps.N; i++ {
+		pr := &Provider{id: strconv.Itoa(ps.FirstID + i)}
+		if i < len(names) {
+			pr.specialty = names[i]
+		} else {
+			pr.specialty = specialties.RandCode(rnd)
+		}
+		if locator != nil {
+			pr.geoCode = locator.RandCode(rnd)
+		}
+		ps.pool = append(ps.pool, pr)
+		ps.bySpecialty[pr.specialty] = append(ps.bySpecialty[pr.specialty], pr)
+		if pr.specialty == ps.GPSpecialty {
+			ps.gpByGeoCode[pr.geoCode] = append(ps.gpByGeoCode[pr.geoCode], pr)
+		}
+	}
+	return nil
+}
+
+// hasSpecialty reports whether the providers config includes a specialty
+func (ps *Providers) hasSpecialty(name string) bool {
+	for _, s := range ps.Specialties {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// regularGP returns a GP practicing in geoCode, or any GP if there is none.
+func (ps *Providers) regularGP(rnd *rand.Rand, geoCode string) *Provider {
+	gps := ps.gpByGeoCode[geoCode]
+	if len(gps) == 0 {
+		gps = ps.bySpecialty[ps.GPSpecialty]
+	}
+	return gps[rnd.Intn(len(gps))]
+}
+
+// specialist returns a random provider of a specialty
+func (ps *Providers) specialist(rnd *rand.Rand, specialty string) *Provider {
+	providers := ps.bySpecialty[specialty]
+	return providers[rnd.Intn(len(providers))]
+}
+
+// providerFor returns the provider of a clinic encounter for a disease: the specialist the person was
+// referred to for the disease with probability disease.ReferralProb, otherwise the person's regular GP.
+func (p *Person) providerFor(disease *Disease) *Provider {
+	ps := p.config.Providers
+	if p.gp == nil {
+		p.gp = ps.regularGP(p.rnd, p.geoCode)
+	}
+	if disease.Specialty == "" || p.rnd.Float64() >= disease.ReferralProb {
+		return p.gp
+	}
+	if p.specialists == nil {
+		p.specialists = make(map[string]*Provider)
+	}
+	if p.specialists[disease.Specialty] == nil {
+		p.specialists[disease.Specialty] = ps.specialist(p.rnd, disease.Specialty)
+	}
+	return p.specialists[disease.Specialty]
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	ps := &Providers{
+		N: 20,
+		Specialties: []*Specialty{
+			{Name: "GP", Prob: 0.8},
+			{Name: "endocrinology", Prob: 0.2},
+		},
+	}
+	if err := ps.generate(nil, rnd); err != nil {
+		t.Fatal(err)
+	}
+	if len(ps.pool) != 20 || len(ps.bySpecialty["endocrinology"]) == 0 {
+		t.Fatalf("generated %d providers, %d endocrinologists", len(ps.pool), len(ps.bySpecialty["endocrinology"]))
+	}
+	p := &Person{config: &Config{Providers: ps}, rnd: rnd}
+	diabetes := &Disease{Specialty: "endocrinology", ReferralProb: 1}
+	specialist := p.providerFor(diabetes)
+	if specialist.specialty != "endocrinology" {
+		t.Errorf("referred to %s, want endocrinology", specialist.specialty)
+	}
+	if p.gp == nil || p.gp.specialty != "GP" {
+		t.Errorf("regular GP not assigned")
+	}
+	diabetes.ReferralProb = 0
+	for i := 0; i < 10; i++ {
+		if got := p.providerFor(diabetes); got != p.gp {
+			t.Fatalf("visit with %s, want regular GP %s", got.id, p.gp.id)
+		}
+	}
+	diabetes.ReferralProb = 1
+	if got := p.providerFor(diabetes); got != specialist {
+		t.Errorf("referred to a different specialist %s, want %s", got.id, specialist.id)
+	}
+	if err := (&Providers{N: 5, Specialties: []*Specialty{{Name: "cardiology", Prob: 1}}}).generate(nil, rnd); err == nil {
+		t.Errorf("providers without GPs should fail")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxAge is the upper age of an open-ended age group, eg 85+
+const maxAge = 100
+
+// pyramid samples sex and birthdate from the counts or proportions of people by age group and sex
+type pyramid struct {
+	groups []*AgeSexGroup
+	cells  *Lookup // code is 2*group index + sex
+}
+
+func newPyramid(groups []*AgeSexGroup) (*pyramid, error) {
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("pyramid must have at least 1 age group")
+	}
+	codes := make([]string, 0, 2*len(groups))
+	weights := make([]float64, 0, 2*len(groups))
+	for i, g := range groups {
+		if g.MinAge < 0 || g.MaxAge < g.MinAge || g.Male < 0 || g.Female < 0 {
+			return nil, fmt.Errorf("invalid pyramid age group %d-%d", g.MinAge, g.MaxAge)
+		}
+		codes = append(codes, strconv.Itoa(2*i), strconv.Itoa(2*i+1))
+		weights = append(weights, g.Male, g.Female)
+	}
+	cells, err := newLookup("age_sex_group", codes, weights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pyramid: %s", err)
+	}
+	return &pyramid{groups: groups, cells: cells}, nil
+}
+
+// sample returns the sex (0 male 1 female) and birthdate of a random person of the population whose
+// structure on date is described by the pyramid. Ages are uniform within an age group.
+func (pr *pyramid) sample(rnd *rand.Rand, date int64) (sex int, dob int64) {
+	cell, _ := strconv.Atoi(pr.cells.RandCode(rnd))
+	g := pr.groups[cell/2]
+	age := RangeInt(rnd, g.MinAge, g.MaxAge)
+	on := toTime(date)
+	return cell % 2, RangeDate(rnd, on.AddDate(-age-1, 0, 1).Unix(), on.AddDate(-age, 0, 0).Unix())
+}
+
+// loadPyramid loads the population by age group and sex from a csv file with the fields min_age, max_age,
+// male and female in any order, or age_group (eg 0-4 or 85+), male and female. male and female are counts
+// or proportions.
+func loadPyramid(fileName string) ([]*AgeSexGroup, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	csv := csv.NewReader(file)
+	// Lines beginning with "/" without preceding whitespace are ignored.
+	csv.Comment = '/'
+	header, err := csv.Read()
+	switch {
+	case err == io.EOF:
+		return nil, fmt.Errorf("empty csv file")
+	case err != nil:
+		return nil, err
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	_, hasGroup := col["age_group"]
+	_, hasMin := col["min_age"]
+	_, hasMax := col["max_age"]
+	_, hasMale := col["male"]
+	_, hasFemale := col["female"]
+	if !hasMale || !hasFemale || !hasGroup && !(hasMin && hasMax) {
+		return nil, fmt.Errorf("required field names are missing. The fields must be named 'min_age', 'max_age' (or 'age_group'), 'male' and 'female'")
+	}
+	var groups []*AgeSexGroup
+	recNum := 1
+	for {
+		record, err := csv.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recNum++
+		field := func(name string) string { return strings.TrimSpace(record[col[name]]) }
+		g := &AgeSexGroup{}
+		if hasGroup {
+			g.MinAge, g.MaxAge, err = parseAgeGroup(field("age_group"))
+		} else {
+			if g.MinAge, err = strconv.Atoi(field("min_age")); err == nil {
+				g.MaxAge, err = strconv.Atoi(field("max_age"))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid age group in line number %d: %s", recNum, err)
+		}
+		if g.Male, err = strconv.ParseFloat(field("male"), 64); err != nil {
+			return nil, fmt.Errorf("invalid male value in line number %d: %s", recNum, err)
+		}
+		if g.Female, err = strconv.ParseFloat(field("female"), 64); err != nil {
+			return nil, fmt.Errorf("invalid female value in line number %d: %s", recNum, err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// parseAgeGroup parses age groups such as 0-4, 5 or 85+; open-ended groups end at maxAge
+func parseAgeGroup(s string) (from, to int, err error) {
+	if strings.HasSuffix(s, "+") {
+		from, err = strconv.Atoi(strings.TrimSuffix(s, "+"))
+		return from, maxAge, err
+	}
+	parts := strings.SplitN(s, "-", 2)
+	if from, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil || len(parts) == 1 {
+		return from, from, err
+	}
+	to, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	return from, to, err
+}
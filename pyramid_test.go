@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drgo/sim/stattest"
+)
+
+func TestPyramid(t *testing.T) {
+	groups, err := loadPyramid("./population-pyramid.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 18 || groups[0].MinAge != 0 || groups[0].MaxAge != 4 || groups[17].MinAge != 85 || groups[17].MaxAge != maxAge {
+		t.Fatalf("got %d groups, first %+v, last %+v", len(groups), groups[0], groups[len(groups)-1])
+	}
+	pr, err := newPyramid([]*AgeSexGroup{{MinAge: 20, MaxAge: 24, Female: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC).Unix()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		sex, dob := pr.sample(rnd, date)
+		if age := ageAt(dob, date); sex != 1 || age < 20 || age > 24 {
+			t.Fatalf("sampled sex %d age %d, want a woman aged 20-24", sex, age)
+		}
+	}
+	// ages and sexes follow the pyramid
+	pr, err = newPyramid(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make([]int, 2*len(groups))
+	for i := 0; i < 50000; i++ {
+		sex, dob := pr.sample(rnd, date)
+		age := ageAt(dob, date)
+		for j, g := range groups {
+			if age >= g.MinAge && age <= g.MaxAge {
+				counts[2*j+sex]++
+			}
+		}
+	}
+	stattest.CheckCounts(t, counts, pr.cells.Probs, stattest.Alpha)
+	for _, tt := range []struct {
+		group    string
+		from, to int
+	}{
+		{"0-4", 0, 4},
+		{"85+", 85, maxAge},
+		{"7", 7, 7},
+	} {
+		if from, to, err := parseAgeGroup(tt.group); err != nil || from != tt.from || to != tt.to {
+			t.Errorf("parseAgeGroup(%q) = %d, %d, %v, want %d, %d", tt.group, from, to, err, tt.from, tt.to)
+		}
+	}
+}
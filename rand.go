@@ -8,16 +8,16 @@ import (
 
 // RangeInt returns an int in a range of two ints.
 // it panics if max-min <0
-func RangeInt(min, max int) int {
+func RangeInt(rnd *rand.Rand, min, max int) int {
 	// if max-min <= 0 {
 	// 	log.Printf("RangeInt max %d min %d max-min %d", max, min, max-min)
 	// }
-	return rand.Intn(max-min+1) + min
+	return rnd.Intn(max-min+1) + min
 }
 
 // Normal returns a draw from a normally distributed dis with desired mean and sd
-func Normal(mean, sd float64) float64 {
-	return rand.NormFloat64()*sd + mean
+func Normal(rnd *rand.Rand, mean, sd float64) float64 {
+	return rnd.NormFloat64()*sd + mean
 }
 
 // DateFromYear returns a valid date from a year and random month and day
@@ -25,8 +25,8 @@ func Normal(mean, sd float64) float64 {
 // 	return rand.Intn(max-min+1) + min
 // }
 
-func RangeDate(min, max int64) int64 {
-	return rand.Int63n(max-min+1) + min
+func RangeDate(rnd *rand.Rand, min, max int64) int64 {
+	return rnd.Int63n(max-min+1) + min
 }
 
 func toTime(unix int64) time.Time {
@@ -99,7 +99,7 @@ type Choice struct {
 // relative.  E.g. if you have two choices both weighted 3, they will be
 // returned equally often; and each will be returned 3 times as often as a
 // choice weighted 1.
-func WeightedChoice(choices []Choice) (Choice, error) {
+func WeightedChoice(rnd *rand.Rand, choices []Choice) (Choice, error) {
 	// Based on this algorithm:
 	//     http://eli.thegreenplace.net/2010/01/22/weighted-random-generation-in-python/
 	var ret Choice
@@ -107,7 +107,10 @@ func WeightedChoice(choices []Choice) (Choice, error) {
 	for _, c := range choices {
 		sum += c.Weight
 	}
-	r := RangeInt(0, sum)
+	if sum <= 0 {
+		return ret, errors.New("WeightedChoice requires a choice of positive weight")
+	}
+	r := RangeInt(rnd, 0, sum-1)
 	for _, c := range choices {
 		r -= c.Weight
 		if r < 0 {
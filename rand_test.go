@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drgo/sim/stattest"
+)
 
 func TestNormal(t *testing.T) {
 	type args struct {
@@ -12,13 +17,47 @@ func TestNormal(t *testing.T) {
 		args args
 		want float64
 	}{
-		{"", args{5.0, 1.0}, 5},
+		{"", args{5.0, 0}, 5},
 	}
+	rnd := rand.New(rand.NewSource(1))
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := Normal(tt.args.mean, tt.args.sd); got != tt.want {
+			if got := Normal(rnd, tt.args.mean, tt.args.sd); got != tt.want {
 				t.Errorf("Normal() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestWeightedChoice(t *testing.T) {
+	choices := []Choice{{Weight: 3, Item: "a"}, {Weight: 0, Item: "b"}, {Weight: 1, Item: "c"}}
+	rnd := rand.New(rand.NewSource(1))
+	counts := make([]int, len(choices))
+	for i := 0; i < 20000; i++ {
+		c, err := WeightedChoice(rnd, choices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range choices {
+			if choices[j].Item == c.Item {
+				counts[j]++
+			}
+		}
+	}
+	stattest.CheckCounts(t, counts, []float64{3, 0, 1}, stattest.Alpha)
+}
+
+func TestWeightedChoiceWeights(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// r is drawn from 0 to sum-1, so a single choice is always returned
+	for i := 0; i < 100; i++ {
+		if c, err := WeightedChoice(rnd, []Choice{{Weight: 0, Item: "a"}, {Weight: 1, Item: "b"}}); err != nil || c.Item != "b" {
+			t.Fatalf("got %v, %v, want b", c.Item, err)
+		}
+	}
+	for _, choices := range [][]Choice{nil, {{Weight: 0, Item: "a"}}} {
+		if _, err := WeightedChoice(rnd, choices); err == nil {
+			t.Errorf("%v: want an error without a positive weight", choices)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// isEncounterTable reports whether the rates per year of coverage of the records of a table are reported
+func isEncounterTable(category string) bool {
+	return category == "hosp" || category == "clinic" || category == "rx"
+}
+
+// runReport summarizes the generated data to check at a glance that it matches the configuration.
+// The writer of each table adds its records to the table's own tableStats, so no locking is needed.
+type runReport struct {
+	config *Config
+	tables map[string]*tableStats
+}
+
+// tableStats holds the statistics of the records of a table
+type tableStats struct {
+	fields    map[string]int // column by field name
+	rows      int
+	bySubject map[string]int       // records by subject_id
+	years     map[string]float64   // years of coverage by subject_id (person)
+	sexes     map[string]int       // sex by subject_id (person)
+	ages      map[int][2]int       // people by age and sex (person)
+	stays     map[string][]float64 // lengths of stay in days by code (hosp)
+	codes     map[string]int       // records by code (rx)
+}
+
+// Report is the run report written to report.json
+type Report struct {
+	People     int                `json:"people"`
+	Tables     []*TableReport     `json:"tables"`
+	Prevalence []PrevalenceReport `json:"prevalence"`
+	Ages       []AgeGroupReport   `json:"ages"`
+	Stays      []StayReport       `json:"length_of_stay,omitempty"`
+	Dins       []DINReport        `json:"dins,omitempty"`
+}
+
+// TableReport holds the rows of a table and the distribution over people of their records.
+// Rates are records per year of coverage.
+type TableReport struct {
+	Name              string   `json:"name"`
+	Rows              int      `json:"rows"`
+	PeopleWithRecords int      `json:"people_with_records"`
+	RecordsPerPerson  Summary  `json:"records_per_person"`
+	RatePerPerson     *Summary `json:"rate_per_person,omitempty"`
+	Rate              float64  `json:"rate,omitempty"` // records per person-year
+}
+
+// PrevalenceReport compares the observed prevalence of a disease by sex to the configured one
+type PrevalenceReport struct {
+	Disease    string  `json:"disease"`
+	Sex        string  `json:"sex"`
+	People     int     `json:"people"`
+	Cases      int64   `json:"cases"`
+	Observed   float64 `json:"observed"`
+	Configured float64 `json:"configured"`
+}
+
+// AgeGroupReport holds the number of people of an age group by sex
+type AgeGroupReport struct {
+	Group  string `json:"group"`
+	Male   int    `json:"male"`
+	Female int    `json:"female"`
+}
+
+// StayReport holds the distribution of the lengths of stay in days of the hospitalizations with the
+// code (icd10) of a disease and the mean of the stay_length of the disease's hospitalization entry
+type StayReport struct {
+	Disease string `json:"disease"`
+	Code    string `json:"code"`
+	Summary
+	ConfiguredMean float64 `json:"configured_mean"`
+}
+
+// DINReport compares the share of a DIN among the prescriptions of a disease to its configured prob
+type DINReport struct {
+	Disease    string  `json:"disease"`
+	DIN        string  `json:"din"`
+	Count      int     `json:"count"`
+	Observed   float64 `json:"observed"`
+	Configured float64 `json:"configured"`
+}
+
+// Summary describes the distribution of a variable
+type Summary struct {
+	N    int     `json:"n"`
+	Mean float64 `json:"mean"`
+	SD   float64 `json:"sd"`
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	Max  float64 `json:"max"`
+}
+
+func newRunReport(config *Config) *runReport {
+	r := &runReport{config: config, tables: make(map[string]*tableStats, len(config.outputs))}
+	for _, category := range config.outputs {
+		t := &tableStats{fields: make(map[string]int), bySubject: make(map[string]int)}
+		for i, name := range strings.Split(config.fieldNames[category], ",") {
+			t.fields[name] = i
+		}
+		switch category {
+		case "person":
+			t.years, t.sexes, t.ages = make(map[string]float64), make(map[string]int), make(map[int][2]int)
+		case "hosp":
+			t.stays = make(map[string][]float64)
+		case "rx":
+			t.codes = make(map[string]int)
+		}
+		r.tables[category] = t
+	}
+	return r
+}
+
+// add adds a record of a table; it is called by the table's writer only
+func (r *runReport) add(category string, record []string) {
+	t := r.tables[category]
+	t.rows++
+	subject := record[t.fields["subject_id"]]
+	t.bySubject[subject]++
+	switch category {
+	case "person":
+		sex, _ := strconv.Atoi(record[t.fields["gender"]])
+		age, _ := strconv.Atoi(record[t.fields["age"]])
+		t.sexes[subject] = sex
+		counts := t.ages[age]
+		counts[sex]++
+		t.ages[age] = counts
+		t.years[subject] = days(record[t.fields["coverage_start"]], record[t.fields["coverage_end"]]) / 365.25
+	case "hosp":
+		code := record[t.fields["code"]]
+		t.stays[code] = append(t.stays[code], days(record[t.fields["service_date"]], record[t.fields["discharge_date"]]))
+	case "rx":
+		t.codes[record[t.fields["code"]]]++
+	}
+}
+
+// days returns the number of days between two dates formatted as yyyy-mm-dd
+func days(from, to string) float64 {
+	start, err1 := time.Parse(dateLayoutISO, from)
+	end, err2 := time.Parse(dateLayoutISO, to)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return end.Sub(start).Hours() / 24
+}
+
+// report returns the report of the records added
+func (r *runReport) report() *Report {
+	persons := r.tables["person"]
+	rep := &Report{People: persons.rows}
+	for _, category := range r.config.outputs {
+		t := r.tables[category]
+		tr := &TableReport{Name: category, Rows: t.rows, PeopleWithRecords: len(t.bySubject)}
+		counts := make([]float64, 0, len(persons.years))
+		for subject := range persons.years {
+			counts = append(counts, float64(t.bySubject[subject]))
+		}
+		tr.RecordsPerPerson = summarize(counts)
+		if isEncounterTable(category) {
+			rates := make([]float64, 0, len(persons.years))
+			years := 0.0
+			for subject, y := range persons.years {
+				years += y
+				if y > 0 {
+					rates = append(rates, float64(t.bySubject[subject])/y)
+				}
+			}
+			s := summarize(rates)
+			tr.RatePerPerson = &s
+			if years > 0 {
+				tr.Rate = float64(t.rows) / years
+			}
+		}
+		rep.Tables = append(rep.Tables, tr)
+	}
+	var people [2]int
+	for _, sex := range persons.sexes {
+		people[sex]++
+	}
+	for _, d := range r.config.Diseases {
+		for sex, name := range []string{"male", "female"} {
+			p := PrevalenceReport{Disease: d.Name, Sex: name, People: people[sex], Cases: d.cases[sex], Configured: d.PrevalenceMale}
+			if sex == 1 {
+				p.Configured = d.PrevalenceFemale
+			}
+			if people[sex] > 0 {
+				p.Observed = float64(p.Cases) / float64(people[sex])
+			}
+			rep.Prevalence = append(rep.Prevalence, p)
+		}
+	}
+	for from := 0; from <= 85; from += 5 {
+		g := AgeGroupReport{Group: fmt.Sprintf("%d-%d", from, from+4)}
+		to := from + 4
+		if from == 85 {
+			g.Group, to = "85+", math.MaxInt32
+		}
+		for age, counts := range persons.ages {
+			if age >= from && age <= to {
+				g.Male += counts[0]
+				g.Female += counts[1]
+			}
+		}
+		rep.Ages = append(rep.Ages, g)
+	}
+	if hosp := r.tables["hosp"]; hosp != nil {
+		for _, d := range r.config.Diseases {
+			s := StayReport{Disease: d.Name, Code: d.Icd10, Summary: summarize(hosp.stays[d.Icd10])}
+			if h := d.Hospitalization; h != nil && h.StayLength.Distribution != nil {
+				s.ConfiguredMean = h.StayLength.Mean()
+			}
+			rep.Stays = append(rep.Stays, s)
+		}
+	}
+	if rx := r.tables["rx"]; rx != nil {
+		for _, d := range r.config.Diseases {
+			total, sum := 0, 0.0
+			for _, din := range d.Dins {
+				total += rx.codes[din.DIN]
+				sum += din.Prob
+			}
+			for _, din := range d.Dins {
+				dr := DINReport{Disease: d.Name, DIN: din.DIN, Count: rx.codes[din.DIN], Configured: din.Prob / sum}
+				if total > 0 {
+					dr.Observed = float64(dr.Count) / float64(total)
+				}
+				rep.Dins = append(rep.Dins, dr)
+			}
+		}
+	}
+	return rep
+}
+
+// summarize returns the summary of values, which are sorted
+func summarize(values []float64) Summary {
+	s := Summary{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+	sort.Float64s(values)
+	sum, sumSq := 0.0, 0.0
+	for _, v := range values {
+		sum += v
+		sumSq += v * v
+	}
+	s.Mean = sum / float64(s.N)
+	s.SD = math.Sqrt(math.Max(0, sumSq/float64(s.N)-s.Mean*s.Mean))
+	s.Min, s.Max = values[0], values[s.N-1]
+	s.P50 = values[(s.N-1)/2]
+	s.P90 = values[int(0.9*float64(s.N-1))]
+	return s
+}
+
+// WriteText writes the report in human-readable form
+func (rep *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "people: %d\n\n", rep.People)
+	fmt.Fprintln(tw, "table\trows\tpeople with records\trecords per person mean\tp50\tp90\tmax\trate per person-year\t")
+	for _, t := range rep.Tables {
+		rate := ""
+		if t.RatePerPerson != nil {
+			rate = fmt.Sprintf("%.3f", t.Rate)
+		}
+		r := t.RecordsPerPerson
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%g\t%g\t%g\t%s\t\n", t.Name, t.Rows, t.PeopleWithRecords, r.Mean, r.P50, r.P90, r.Max, rate)
+	}
+	fmt.Fprintln(tw, "\nencounter rate per person-year\tmean\tsd\tp50\tp90\tmax\t")
+	for _, t := range rep.Tables {
+		if r := t.RatePerPerson; r != nil {
+			fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n", t.Name, r.Mean, r.SD, r.P50, r.P90, r.Max)
+		}
+	}
+	fmt.Fprintln(tw, "\ndisease\tsex\tpeople\tcases\tprevalence\tconfigured\t")
+	for _, p := range rep.Prevalence {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.4f\t%.4f\t\n", p.Disease, p.Sex, p.People, p.Cases, p.Observed, p.Configured)
+	}
+	fmt.Fprintln(tw, "\nage\tmale\tfemale\t")
+	for _, g := range rep.Ages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", g.Group, g.Male, g.Female)
+	}
+	if len(rep.Stays) > 0 {
+		fmt.Fprintln(tw, "\nlength of stay (days)\tcode\tn\tmean\tsd\tmin\tp50\tp90\tmax\tconfigured mean\t")
+		for _, s := range rep.Stays {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%.2f\t%g\t%g\t%g\t%g\t%.2f\t\n", s.Disease, s.Code, s.N, s.Mean, s.SD, s.Min, s.P50, s.P90, s.Max, s.ConfiguredMean)
+		}
+	}
+	if len(rep.Dins) > 0 {
+		fmt.Fprintln(tw, "\ndisease\tDIN\tcount\tshare\tconfigured\t")
+		for _, d := range rep.Dins {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%.3f\t\n", d.Disease, d.DIN, d.Count, d.Observed, d.Configured)
+		}
+	}
+	return tw.Flush()
+}
+
+// save writes the report to report.json and report.txt in dir and prints it
+func (rep *Report) save(dir string) error {
+	data, err := json.MarshalIndent(rep, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "report.json"), data, 0644); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, "report.txt"))
+	if err != nil {
+		return err
+	}
+	if err = rep.WriteText(io.MultiWriter(f, os.Stdout)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   Summary
+	}{
+		{nil, Summary{}},
+		{[]float64{3}, Summary{N: 1, Mean: 3, Min: 3, P50: 3, P90: 3, Max: 3}},
+		{[]float64{4, 2, 0, 6}, Summary{N: 4, Mean: 3, SD: 2.23606797749979, Min: 0, P50: 2, P90: 4, Max: 6}},
+	}
+	for _, tt := range tests {
+		if got := summarize(tt.values); got != tt.want {
+			t.Errorf("summarize(%v) = %+v, want %+v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	config := &Config{
+		outputs: []string{"person", "hosp", "rx"},
+		fieldNames: map[string]string{
+			"person": "subject_id,gender,birthdate,age,coverage_start,coverage_end",
+			"hosp":   "subject_id,service_date,discharge_date,code",
+			"rx":     "subject_id,service_date,code",
+		},
+		Diseases: []*Disease{
+			{Name: "diabetes", Icd10: "E11", PrevalenceMale: 0.5, PrevalenceFemale: 0.4, Hospitalization: &Hospitalization{StayLength: statsDist(Stats{Mean: 4, SD: 1})},
+				Dins: []DIN{{DIN: "1", Prob: 3}, {DIN: "2", Prob: 1}}, cases: [2]int64{1, 0}},
+			{Name: "copd", Icd10: "J44", Hospitalization: &Hospitalization{StayLength: statsDist(Stats{Mean: 7, SD: 2})}},
+		},
+	}
+	r := newRunReport(config)
+	for _, rec := range [][]string{
+		{"person", "1", "0", "1950-01-01", "70", "2010-01-01", "2012-01-01"},
+		{"person", "2", "1", "2000-06-01", "19", "2010-01-01", "2011-01-01"},
+		{"hosp", "1", "2010-03-01", "2010-03-05", "E11"},
+		{"hosp", "1", "2011-03-01", "2011-03-03", "E11"},
+		{"rx", "1", "2010-03-01", "1"},
+		{"rx", "1", "2010-04-01", "1"},
+		{"rx", "1", "2010-05-01", "2"},
+		{"rx", "2", "2010-05-01", "3"},
+	} {
+		r.add(rec[0], rec[1:])
+	}
+	rep := r.report()
+	if rep.People != 2 || len(rep.Tables) != 3 {
+		t.Fatalf("got %d people and %d tables, want 2 and 3", rep.People, len(rep.Tables))
+	}
+	hosp := rep.Tables[1]
+	if hosp.Rows != 2 || hosp.PeopleWithRecords != 1 || hosp.RecordsPerPerson.Max != 2 {
+		t.Errorf("hosp = %+v", hosp)
+	}
+	if hosp.RatePerPerson == nil || hosp.Rate < 0.66 || hosp.Rate > 0.67 {
+		t.Errorf("hosp rate = %v, want 2 stays in 3 person-years", hosp.Rate)
+	}
+	if len(rep.Stays) != 2 || rep.Stays[0].Mean != 3 || rep.Stays[0].ConfiguredMean != 4 {
+		t.Errorf("stays = %+v, want mean 3 and configured mean 4 for diabetes", rep.Stays)
+	} else if s := rep.Stays[1]; s.Disease != "copd" || s.N != 0 || s.ConfiguredMean != 7 {
+		t.Errorf("copd stays = %+v, want no stays and configured mean 7", s)
+	}
+	if p := rep.Prevalence[0]; p.Sex != "male" || p.Observed != 1 || p.Configured != 0.5 {
+		t.Errorf("male prevalence = %+v", p)
+	}
+	if p := rep.Prevalence[1]; p.Sex != "female" || p.Observed != 0 || p.Configured != 0.4 {
+		t.Errorf("female prevalence = %+v", p)
+	}
+	for _, g := range rep.Ages {
+		want := [2]int{}
+		switch g.Group {
+		case "70-74":
+			want[0] = 1
+		case "15-19":
+			want[1] = 1
+		}
+		if g.Male != want[0] || g.Female != want[1] {
+			t.Errorf("age %s = %d male, %d female, want %v", g.Group, g.Male, g.Female, want)
+		}
+	}
+	if len(rep.Dins) != 2 || rep.Dins[0].Count != 2 || rep.Dins[0].Observed*3 != 2 || rep.Dins[0].Configured != 0.75 {
+		t.Errorf("dins = %+v", rep.Dins)
+	}
+	var text bytes.Buffer
+	if err := rep.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "diabetes") {
+		t.Errorf("text report lacks the prevalence of diabetes:\n%s", text.String())
+	}
+}
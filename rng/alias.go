@@ -0,0 +1,90 @@
+/*Copyright 2019 Salah Mahmud. All rights reserved.*/
+
+package rng
+
+import (
+	"math/rand"
+)
+
+// Alias provides constant time sampling from a discrete distribution
+// modified from go-discrererand Gryski <damian@gryski.com>
+// This is an implementation of Vose's alias method for
+// choosing elements from a discrete distribution.
+// For a full description of the algorithm, see http://www.keithschwarz.com/darts-dice-coins/
+type Alias struct {
+	rnd    *rand.Rand
+	labels []int
+	prob   []float64
+}
+
+// NewAlias constructs an Alias sampler of the discrete distribution given by weights, which
+// need not sum to 1. Random numbers are drawn from src or, if src is nil, from the global
+// math/rand source.
+func NewAlias(weights []float64, src rand.Source) (*Alias, error) {
+	p, err := normalize(weights)
+	if err != nil {
+		return nil, err
+	}
+	n := len(p)
+	a := &Alias{
+		rnd:    newRand(src),
+		labels: make([]int, n),
+		prob:   make([]float64, n),
+	}
+	for i := range p {
+		p[i] *= float64(n)
+	}
+
+	var small worklist
+	var large worklist
+
+	for i, pi := range p {
+		if pi < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	for len(large) > 0 && len(small) > 0 {
+		l := small.pop()
+		g := large.pop()
+		a.prob[l] = p[l]
+		a.labels[l] = g
+
+		p[g] = (p[g] + p[l]) - 1
+		if p[g] < 1 {
+			small.push(g)
+		} else {
+			large.push(g)
+		}
+	}
+
+	for len(large) > 0 {
+		g := large.pop()
+		a.prob[g] = 1
+	}
+
+	for len(small) > 0 {
+		l := small.pop()
+		a.prob[l] = 1
+	}
+
+	return a, nil
+}
+
+// Sample returns the next random value from the discrete distribution
+func (a *Alias) Sample() int {
+	return a.SampleRand(a.rnd)
+}
+
+// SampleRand returns the next random value from the discrete distribution using r
+func (a *Alias) SampleRand(r *rand.Rand) int {
+	i := r.Intn(len(a.prob))
+	if r.Float64() < a.prob[i] {
+		return i
+	}
+	return a.labels[i]
+}
+
+func (a *Alias) Len() int { return len(a.prob) }
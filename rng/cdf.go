@@ -0,0 +1,46 @@
+package rng
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// CDF samples from a discrete distribution by binary search of its cumulative distribution
+// function: setup is faster than Alias, sampling takes O(log n).
+// source: https://codereview.stackexchange.com/questions/194391/weighted-probability-in-go
+type CDF struct {
+	rnd    *rand.Rand
+	bounds []float64 // cumulative probabilities
+}
+
+// NewCDF constructs a CDF sampler of the discrete distribution given by weights, which need not
+// sum to 1. Random numbers are drawn from src or, if src is nil, from the global math/rand source.
+func NewCDF(weights []float64, src rand.Source) (*CDF, error) {
+	bounds, err := normalize(weights)
+	if err != nil {
+		return nil, err
+	}
+	sum := 0.0
+	for i, p := range bounds {
+		sum += p
+		bounds[i] = sum
+	}
+	bounds[len(bounds)-1] = 1 // guards against rounding errors
+	return &CDF{rnd: newRand(src), bounds: bounds}, nil
+}
+
+// Sample returns the next random value from the discrete distribution. Outcomes of weight 0,
+// whose bound equals the previous one, are never returned.
+func (c *CDF) Sample() int {
+	return c.SampleRand(c.rnd)
+}
+
+// SampleRand returns the next random value from the discrete distribution using r
+func (c *CDF) SampleRand(r *rand.Rand) int {
+	u := r.Float64()
+	return sort.Search(len(c.bounds), func(i int) bool {
+		return c.bounds[i] > u
+	})
+}
+
+func (c *CDF) Len() int { return len(c.bounds) }
@@ -0,0 +1,44 @@
+package rng
+
+import "math/rand"
+
+// Reservoir samples from a discrete distribution by weighted reservoir sampling of a single item:
+// there is no setup and sampling takes O(n), so it suits small distributions and distributions
+// sampled only a few times.
+type Reservoir struct {
+	rnd     *rand.Rand
+	weights []float64
+}
+
+// NewReservoir constructs a Reservoir sampler of the discrete distribution given by weights, which
+// need not sum to 1. Random numbers are drawn from src or, if src is nil, from the global math/rand
+// source.
+func NewReservoir(weights []float64, src rand.Source) (*Reservoir, error) {
+	if _, err := normalize(weights); err != nil {
+		return nil, err
+	}
+	return &Reservoir{rnd: newRand(src), weights: append([]float64(nil), weights...)}, nil
+}
+
+// Sample returns the next random value from the discrete distribution. Item i replaces the
+// current choice with probability weights[i] over the sum of the weights seen so far.
+func (r *Reservoir) Sample() int {
+	return r.SampleRand(r.rnd)
+}
+
+// SampleRand returns the next random value from the discrete distribution using rnd
+func (r *Reservoir) SampleRand(rnd *rand.Rand) int {
+	chosen, sum := 0, 0.0
+	for i, w := range r.weights {
+		if w == 0 {
+			continue
+		}
+		sum += w
+		if rnd.Float64()*sum < w {
+			chosen = i
+		}
+	}
+	return chosen
+}
+
+func (r *Reservoir) Len() int { return len(r.weights) }
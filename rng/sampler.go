@@ -0,0 +1,64 @@
+// Package rng provides random number generation for the simulation, including samplers of
+// discrete distributions.
+package rng
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Sampler samples from a discrete distribution: Sample returns index i with probability
+// weights[i]/sum(weights), where weights are the weights the sampler was constructed with.
+// Sample is safe for concurrent use only if the sampler's source is; SampleRand is safe if r
+// is used by a single goroutine.
+type Sampler interface {
+	Sample() int
+	// SampleRand is Sample drawing random numbers from r instead of the sampler's source,
+	// eg to share a sampler between goroutines with their own streams
+	SampleRand(r *rand.Rand) int
+	// Len returns the number of outcomes
+	Len() int
+}
+
+// newRand returns a generator using src, or the global math/rand source, which is safe for
+// concurrent use, if src is nil
+func newRand(src rand.Source) *rand.Rand {
+	if src == nil {
+		src = globalSource{}
+	}
+	return rand.New(src)
+}
+
+// globalSource is a rand.Source reading from the global math/rand source
+type globalSource struct{}
+
+func (globalSource) Int63() int64 { return rand.Int63() }
+
+func (globalSource) Uint64() uint64 { return rand.Uint64() }
+
+// Seed does nothing: the global source is seeded by its owner
+func (globalSource) Seed(int64) {}
+
+// normalize returns weights divided by their sum. Weights must be non-negative and finite
+// and at least one must be > 0.
+func normalize(weights []float64) ([]float64, error) {
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("at least 1 weight is required")
+	}
+	sum := 0.0
+	for i, w := range weights {
+		if w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return nil, fmt.Errorf("weight %d must be a number >= 0, got %v", i, w)
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("sum of weights must be > 0")
+	}
+	probs := make([]float64, len(weights))
+	for i, w := range weights {
+		probs[i] = w / sum
+	}
+	return probs, nil
+}
@@ -0,0 +1,131 @@
+package rng
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/drgo/sim/stattest"
+)
+
+// samplers holds a constructor for each Sampler
+var samplers = []struct {
+	name string
+	new  func(weights []float64, src rand.Source) (Sampler, error)
+}{
+	{"alias", func(w []float64, src rand.Source) (Sampler, error) { return NewAlias(w, src) }},
+	{"cdf", func(w []float64, src rand.Source) (Sampler, error) { return NewCDF(w, src) }},
+	{"reservoir", func(w []float64, src rand.Source) (Sampler, error) { return NewReservoir(w, src) }},
+}
+
+func TestSamplers(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []float64
+	}{
+		{"probabilities", []float64{0.125, 0.2, 0.1, 0.25, 0.1, 0.1, 0.125}},
+		{"counts", []float64{30, 0, 10, 60}},
+		{"single", []float64{2}},
+	}
+	const rounds = 1e6
+	for _, s := range samplers {
+		for _, tt := range tests {
+			t.Run(s.name+" "+tt.name, func(t *testing.T) {
+				sampler, err := s.new(tt.weights, rand.NewSource(0))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sampler.Len() != len(tt.weights) {
+					t.Errorf("Len() = %d, want %d", sampler.Len(), len(tt.weights))
+				}
+				counts := make([]int, len(tt.weights))
+				for i := 0; i < rounds; i++ {
+					counts[sampler.Sample()]++
+				}
+				stattest.CheckCounts(t, counts, tt.weights, stattest.Alpha)
+			})
+		}
+	}
+}
+
+// TestSamplersRandom checks the samplers on random distributions of many values
+func TestSamplersRandom(t *testing.T) {
+	for _, s := range samplers {
+		for _, size := range []int{16, 1000} {
+			weights := generateProbDist64(int64(size), size)
+			sampler, err := s.new(weights, rand.NewSource(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts := make([]int, size)
+			for i := 0; i < 200*size; i++ {
+				counts[sampler.Sample()]++
+			}
+			stattest.CheckCounts(t, counts, weights, stattest.Alpha)
+		}
+	}
+}
+
+func TestSamplerSeeding(t *testing.T) {
+	weights := []float64{0.1, 0.2, 0.3, 0.4}
+	for _, s := range samplers {
+		a, _ := s.new(weights, rand.NewSource(1))
+		b, _ := s.new(weights, rand.NewSource(1))
+		c, _ := s.new(weights, nil)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			if x, y, z := a.Sample(), b.Sample(), c.SampleRand(r); x != y || x != z {
+				t.Fatalf("%s: expected same sequence of values, but at pos %d, got %d, %d and %d", s.name, i, x, y, z)
+			}
+		}
+	}
+}
+
+func TestSamplerWeights(t *testing.T) {
+	for _, weights := range [][]float64{nil, {0, 0}, {0.5, -0.1}, {1, math.NaN()}, {math.Inf(1)}} {
+		for _, s := range samplers {
+			if _, err := s.new(weights, nil); err == nil {
+				t.Errorf("%s: weights %v should be rejected", s.name, weights)
+			}
+		}
+	}
+}
+
+// BenchmarkScalingSamplers performance of Alias should not degrade with increasing distribution size
+func BenchmarkScalingSamplers(b *testing.B) {
+	for _, s := range samplers {
+		for size := 2; size <= 1024; size = size * 2 {
+			name := fmt.Sprintf("%s n levels=%d", s.name, size)
+			b.Run(name, func(b *testing.B) {
+				p := generateProbDist64(1, size)
+				a, err := s.new(p, rand.NewSource(0))
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					a.Sample()
+				}
+			})
+		}
+	}
+}
+
+//utils
+
+// generateProbDist64 returns a random distribution of size probabilities, all > 0
+func generateProbDist64(seed int64, size int) []float64 {
+	rnd := rand.New(rand.NewSource(seed))
+	labels := make([]int, 0, size)
+	sum := 0
+	for i := 0; i < size; i++ {
+		labels = append(labels, 1+rnd.Intn(1000))
+		sum += labels[i]
+	}
+	probabilities := make([]float64, 0, size)
+	for i := 0; i < size; i++ {
+		probabilities = append(probabilities, float64(labels[i])/float64(sum))
+	}
+	return probabilities
+}
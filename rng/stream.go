@@ -0,0 +1,93 @@
+package rng
+
+import "math/bits"
+
+// Stream is a xoroshiro128** pseudo-random number generator (see http://prng.di.unimi.it).
+// It implements rand.Source64, so use rand.New(stream) for floats, ints and normal deviates.
+// A Stream is not safe for concurrent use: give each goroutine its own stream using Split or Derive.
+type Stream struct {
+	s0, s1 uint64
+}
+
+// NewStream returns a stream seeded with seed
+func NewStream(seed uint64) *Stream {
+	s := &Stream{}
+	s.seed(seed)
+	return s
+}
+
+// Derive returns the stream identified by keys, eg a person's index, within the streams of the root
+// seed. The same seed and keys always give the same stream and streams with different keys are
+// independent for simulation purposes, whatever the order in which they are derived.
+func Derive(seed uint64, keys ...uint64) *Stream {
+	sm := seed
+	h := splitMix64(&sm)
+	for _, key := range keys {
+		sm = h ^ key
+		h = splitMix64(&sm) ^ splitMix64(&sm)
+	}
+	return NewStream(h)
+}
+
+func (s *Stream) seed(seed uint64) {
+	s.s0 = splitMix64(&seed)
+	s.s1 = splitMix64(&seed)
+	if s.s0 == 0 && s.s1 == 0 { // the all-zero state is a fixed point
+		s.s1 = 1
+	}
+}
+
+// Seed reseeds the stream
+func (s *Stream) Seed(seed int64) {
+	s.seed(uint64(seed))
+}
+
+// Uint64 returns the next pseudo-random 64-bit value
+func (s *Stream) Uint64() uint64 {
+	s0, s1 := s.s0, s.s1
+	result := bits.RotateLeft64(s0*5, 7) * 9
+	s1 ^= s0
+	s.s0 = bits.RotateLeft64(s0, 24) ^ s1 ^ (s1 << 16)
+	s.s1 = bits.RotateLeft64(s1, 37)
+	return result
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer
+func (s *Stream) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// jumpPoly advances xoroshiro128 by 2^64 steps
+var jumpPoly = [2]uint64{0xdf900294d8f554a5, 0x170865df4b3201fc}
+
+// Jump advances the stream by 2^64 values, as if Uint64 had been called 2^64 times
+func (s *Stream) Jump() {
+	var s0, s1 uint64
+	for _, p := range jumpPoly {
+		for b := uint(0); b < 64; b++ {
+			if p&(1<<b) != 0 {
+				s0 ^= s.s0
+				s1 ^= s.s1
+			}
+			s.Uint64()
+		}
+	}
+	s.s0, s.s1 = s0, s1
+}
+
+// Split returns a stream starting at the current state of s and jumps s, so the returned stream
+// does not overlap the following 2^64 values of s or the streams split from s later on.
+func (s *Stream) Split() *Stream {
+	t := *s
+	s.Jump()
+	return &t
+}
+
+// splitMix64 returns the next value of the SplitMix64 generator with state x
+func splitMix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,95 @@
+package rng
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/drgo/sim/stattest"
+)
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name string
+		jump bool
+		want []uint64
+	}{
+		{"seed 42", false, []uint64{0x69e85b3631381baa, 0x3bc32c541d626e1d, 0x3e35de64b3b378d8}},
+		{"seed 42 jumped", true, []uint64{0x43a69bb2726217fd, 0x2be1f3ffc62e1f4b, 0xa69f7419d9d9bd19}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStream(42)
+			if tt.jump {
+				s.Jump()
+			}
+			for i, want := range tt.want {
+				if got := s.Uint64(); got != want {
+					t.Errorf("value %d = %#x, want %#x", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamJump(t *testing.T) {
+	// jumping commutes with stepping
+	a, b := NewStream(7), NewStream(7)
+	a.Uint64()
+	a.Jump()
+	b.Jump()
+	b.Uint64()
+	if x, y := a.Uint64(), b.Uint64(); x != y {
+		t.Errorf("step then jump gives %#x, jump then step gives %#x", x, y)
+	}
+	root := NewStream(7)
+	first := root.Split()
+	second := root.Split()
+	if *first != *NewStream(7) {
+		t.Errorf("first split stream must start at the root state")
+	}
+	c := NewStream(7)
+	c.Jump()
+	if *second != *c {
+		t.Errorf("second split stream must start 2^64 values after the first")
+	}
+}
+
+func TestDerive(t *testing.T) {
+	if Derive(1, 5).Uint64() != Derive(1, 5).Uint64() {
+		t.Errorf("same seed and keys must give the same stream")
+	}
+	seen := make(map[uint64]string)
+	for _, keys := range [][]uint64{{}, {0}, {1}, {0, 0}, {0, 1}, {1, 0}} {
+		for seed := uint64(0); seed < 3; seed++ {
+			v := Derive(seed, keys...).Uint64()
+			if other, found := seen[v]; found {
+				t.Errorf("seed %d keys %v gives the same stream as %s", seed, keys, other)
+			}
+			seen[v] = fmt.Sprint(seed, keys)
+		}
+	}
+	// a stream is a rand.Source64
+	r := rand.New(Derive(1, 2))
+	if f := r.Float64(); f < 0 || f >= 1 {
+		t.Errorf("Float64() = %v", f)
+	}
+}
+
+func TestStreamUniform(t *testing.T) {
+	uniform := func(x float64) float64 { return math.Max(0, math.Min(1, x)) }
+	for _, key := range []uint64{0, 1, 2} {
+		r := rand.New(Derive(42, key))
+		sample := make([]float64, 100000)
+		for i := range sample {
+			sample[i] = r.Float64()
+		}
+		stattest.CheckSample(t, sample, uniform, stattest.Alpha)
+		counts := make([]int, 10)
+		for i := 0; i < 100000; i++ {
+			counts[r.Intn(10)]++
+		}
+		stattest.CheckCounts(t, counts, []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, stattest.Alpha)
+	}
+}
@@ -0,0 +1,148 @@
+package simula
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mathext"
+)
+
+// Poisson is the Poisson distribution with mean Lambda
+type Poisson struct {
+	Lambda float64
+}
+
+// NewPoisson returns a Poisson distribution; lambda may be 0
+func NewPoisson(lambda float64) (*Poisson, error) {
+	if lambda != 0 {
+		if err := checkPositive("lambda", lambda); err != nil {
+			return nil, err
+		}
+	}
+	return &Poisson{Lambda: lambda}, nil
+}
+
+func (p *Poisson) discrete() {}
+
+func (p *Poisson) Rand(rnd *rand.Rand) float64 {
+	return poissonRand(rnd, p.Lambda)
+}
+
+func (p *Poisson) Mean() float64 { return p.Lambda }
+
+func (p *Poisson) CDF(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	if p.Lambda == 0 {
+		return 1
+	}
+	return mathext.GammaIncRegComp(math.Floor(x)+1, p.Lambda)
+}
+
+func (p *Poisson) Quantile(q float64) float64 {
+	return discreteQuantile(p, q)
+}
+
+// poissonRand returns a draw from a Poisson dis with mean lambda using multiplication of uniforms
+// for small lambda and Hormann's transformed rejection (PTRS) otherwise
+func poissonRand(rnd *rand.Rand, lambda float64) float64 {
+	if lambda < 10 {
+		limit := math.Exp(-lambda)
+		k, prod := 0.0, rnd.Float64()
+		for prod > limit {
+			k++
+			prod *= rnd.Float64()
+		}
+		return k
+	}
+	slam := math.Sqrt(lambda)
+	loglam := math.Log(lambda)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := rnd.Float64() - 0.5
+		v := rnd.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return k
+		}
+		if k < 0 || us < 0.013 && v > us {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/(us*us)+b) <= -lambda+k*loglam-lg {
+			return k
+		}
+	}
+}
+
+// NegativeBinomial is the distribution of the number of failures before the R-th success in
+// trials of success probability P. It is a Poisson distribution whose mean is gamma distributed,
+// eg overdispersed counts of encounters.
+type NegativeBinomial struct {
+	R, P float64
+}
+
+func NewNegativeBinomial(r, p float64) (*NegativeBinomial, error) {
+	if err := checkPositive("r", r); err != nil {
+		return nil, err
+	}
+	if !(p > 0 && p <= 1) {
+		return nil, fmt.Errorf("p must be > 0 and <= 1, got %v", p)
+	}
+	return &NegativeBinomial{R: r, P: p}, nil
+}
+
+func (nb *NegativeBinomial) discrete() {}
+
+func (nb *NegativeBinomial) Rand(rnd *rand.Rand) float64 {
+	if nb.P == 1 {
+		return 0
+	}
+	return poissonRand(rnd, gammaRand(rnd, nb.R)*(1-nb.P)/nb.P)
+}
+
+func (nb *NegativeBinomial) Mean() float64 { return nb.R * (1 - nb.P) / nb.P }
+
+func (nb *NegativeBinomial) CDF(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	if nb.P == 1 {
+		return 1
+	}
+	return mathext.RegIncBeta(nb.R, math.Floor(x)+1, nb.P)
+}
+
+func (nb *NegativeBinomial) Quantile(q float64) float64 {
+	return discreteQuantile(nb, q)
+}
+
+// discreteQuantile returns the smallest integer k >= 0 with d.CDF(k) >= p
+func discreteQuantile(d Distribution, p float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	hi := math.Max(1, math.Ceil(d.Mean()))
+	for d.CDF(hi) < p {
+		if hi > 1e15 {
+			return math.Inf(1)
+		}
+		hi *= 2
+	}
+	lo := -1.0 // d.CDF(lo) < p
+	for hi-lo > 1 {
+		mid := math.Floor((lo + hi) / 2)
+		if d.CDF(mid) >= p {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
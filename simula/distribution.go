@@ -0,0 +1,315 @@
+// Package simula provides parameterised probability distributions for simulations. Distributions
+// draw random numbers from an explicit generator, so each goroutine can use its own stream.
+package simula
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Distribution is a probability distribution. Discrete distributions return integer values.
+type Distribution interface {
+	// Rand returns a random value drawn using the random numbers of rnd
+	Rand(rnd *rand.Rand) float64
+	Mean() float64
+	// CDF returns the probability of a value <= x
+	CDF(x float64) float64
+	// Quantile returns the smallest value x with CDF(x) >= p
+	Quantile(p float64) float64
+}
+
+// discrete is implemented by distributions of integer values
+type discrete interface {
+	discrete()
+}
+
+// params holds the parameters of a distribution by name
+type params map[string]float64
+
+// distribution describes how to build a distribution from one of its sets of parameters
+type distribution struct {
+	paramSets [][]string
+	new       func(p params) (Distribution, error)
+}
+
+// distributions holds the distributions that can be configured by name. min and max truncate any
+// distribution and are not listed.
+var distributions = map[string]distribution{
+	"uniform": {[][]string{{"min", "max"}}, nil}, // built by New: min and max are its parameters
+	"normal": {[][]string{{"mu", "sigma"}, {"mean", "sd"}}, func(p params) (Distribution, error) {
+		if _, found := p["mean"]; found {
+			return NewNormal(p["mean"], p["sd"])
+		}
+		return NewNormal(p["mu"], p["sigma"])
+	}},
+	"truncated_normal": {[][]string{{"mu", "sigma"}, {"mean", "sd"}}, nil}, // a normal truncated by min and/or max
+	"lognormal": {[][]string{{"mu", "sigma"}, {"mean", "sd"}}, func(p params) (Distribution, error) {
+		if _, found := p["mean"]; found {
+			return LogNormalFromMoments(p["mean"], p["sd"])
+		}
+		return NewLogNormal(p["mu"], p["sigma"])
+	}},
+	"gamma": {[][]string{{"shape", "scale"}, {"shape", "rate"}, {"mean", "sd"}}, func(p params) (Distribution, error) {
+		if _, found := p["mean"]; found {
+			return GammaFromMoments(p["mean"], p["sd"])
+		}
+		if rate, found := p["rate"]; found {
+			if rate <= 0 {
+				return nil, fmt.Errorf("rate must be > 0, got %v", rate)
+			}
+			return NewGamma(p["shape"], 1/rate)
+		}
+		return NewGamma(p["shape"], p["scale"])
+	}},
+	"exponential": {[][]string{{"rate"}, {"mean"}}, func(p params) (Distribution, error) {
+		if mean, found := p["mean"]; found {
+			if mean <= 0 {
+				return nil, fmt.Errorf("mean must be > 0, got %v", mean)
+			}
+			return NewExponential(1 / mean)
+		}
+		return NewExponential(p["rate"])
+	}},
+	"weibull": {[][]string{{"shape", "scale"}, {"mean", "sd"}}, func(p params) (Distribution, error) {
+		if _, found := p["mean"]; found {
+			return WeibullFromMoments(p["mean"], p["sd"])
+		}
+		return NewWeibull(p["shape"], p["scale"])
+	}},
+	"gompertz": {[][]string{{"shape", "scale"}}, func(p params) (Distribution, error) {
+		return NewGompertz(p["shape"], p["scale"])
+	}},
+	"beta": {[][]string{{"alpha", "beta"}}, func(p params) (Distribution, error) {
+		return NewBeta(p["alpha"], p["beta"])
+	}},
+	"poisson": {[][]string{{"lambda"}, {"mean"}}, func(p params) (Distribution, error) {
+		if mean, found := p["mean"]; found {
+			return NewPoisson(mean)
+		}
+		return NewPoisson(p["lambda"])
+	}},
+	"negative_binomial": {[][]string{{"r", "p"}, {"mean", "r"}}, func(p params) (Distribution, error) {
+		if mean, found := p["mean"]; found {
+			if mean < 0 {
+				return nil, fmt.Errorf("mean must be >= 0, got %v", mean)
+			}
+			return NewNegativeBinomial(p["r"], p["r"]/(p["r"]+mean))
+		}
+		return NewNegativeBinomial(p["r"], p["p"])
+	}},
+}
+
+// Names returns the names of the distributions that can be configured, sorted
+func Names() []string {
+	names := make([]string, 0, len(distributions))
+	for name := range distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the distribution called name with the given parameters, eg New("gamma",
+// map[string]float64{"shape": 2, "scale": 3}). Each distribution accepts one of several sets of
+// parameters, eg shape and scale or mean and sd. The parameters min and max, if present,
+// truncate the distribution to [min, max].
+func New(name string, parameters map[string]float64) (Distribution, error) {
+	dist, found := distributions[name]
+	if !found {
+		return nil, fmt.Errorf("unknown distribution %q. Must be one of %s", name, strings.Join(Names(), ", "))
+	}
+	p := make(params, len(parameters))
+	for k, v := range parameters {
+		if math.IsNaN(v) {
+			return nil, fmt.Errorf("%s: %s must be a number", name, k)
+		}
+		p[k] = v
+	}
+	min, hasMin := p["min"]
+	max, hasMax := p["max"]
+	if name != "uniform" {
+		delete(p, "min")
+		delete(p, "max")
+	}
+	if err := p.match(dist.paramSets); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	var (
+		d   Distribution
+		err error
+	)
+	switch name {
+	case "uniform":
+		d, err = NewUniform(min, max)
+		hasMin, hasMax = false, false
+	case "truncated_normal":
+		if !hasMin && !hasMax {
+			return nil, fmt.Errorf("%s: min or max is required", name)
+		}
+		d, err = distributions["normal"].new(p)
+	default:
+		d, err = dist.new(p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if !hasMin && !hasMax {
+		return d, nil
+	}
+	if !hasMin {
+		min = math.Inf(-1)
+	}
+	if !hasMax {
+		max = math.Inf(1)
+	}
+	if d, err = Truncate(d, min, max); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return d, nil
+}
+
+// match checks that the parameters are one of sets
+func (p params) match(sets [][]string) error {
+	for _, set := range sets {
+		if len(set) != len(p) {
+			continue
+		}
+		ok := true
+		for _, name := range set {
+			if _, found := p[name]; !found {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return nil
+		}
+	}
+	want := make([]string, len(sets))
+	for i, set := range sets {
+		want[i] = strings.Join(set, " and ")
+	}
+	got := make([]string, 0, len(p))
+	for name := range p {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	return fmt.Errorf("parameters must be %s (and optionally min and max), got %s", strings.Join(want, ", or "), strings.Join(got, ", "))
+}
+
+// Dist is a Distribution configured in JSON by its name and parameters, eg {"dist":"gamma","shape":2,"scale":3}.
+// See New for the parameters.
+type Dist struct {
+	Distribution
+	Name   string
+	Params map[string]float64
+}
+
+// UnmarshalJSON reads a distribution from a JSON object with a dist field holding its name and a
+// numeric field for each parameter
+func (d *Dist) UnmarshalJSON(data []byte) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("a distribution must be an object such as {\"dist\":\"gamma\",\"shape\":2,\"scale\":3}")
+	}
+	name, ok := fields["dist"].(string)
+	if !ok {
+		return fmt.Errorf("a distribution must have a dist field holding its name, one of %s", strings.Join(Names(), ", "))
+	}
+	p := make(map[string]float64, len(fields)-1)
+	for k, v := range fields {
+		if k == "dist" {
+			continue
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: %s must be a number", name, k)
+		}
+		p[k] = f
+	}
+	dist, err := New(name, p)
+	if err != nil {
+		return err
+	}
+	*d = Dist{Distribution: dist, Name: name, Params: p}
+	return nil
+}
+
+// MarshalJSON writes the distribution as read by UnmarshalJSON
+func (d Dist) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(d.Params)+1)
+	for k, v := range d.Params {
+		fields[k] = v
+	}
+	fields["dist"] = d.Name
+	return json.Marshal(fields)
+}
+
+// Truncated is a distribution truncated to [Min, Max]. Values are drawn by inversion of the CDF.
+type Truncated struct {
+	Dist     Distribution
+	Min, Max float64
+	lo, hi   float64 // CDF of Dist below Min and at Max
+}
+
+// Truncate returns d truncated to [min, max]; use infinite bounds to truncate one side only
+func Truncate(d Distribution, min, max float64) (*Truncated, error) {
+	if math.IsNaN(min) || math.IsNaN(max) || min > max {
+		return nil, fmt.Errorf("truncation min (%v) must be <= max (%v)", min, max)
+	}
+	t := &Truncated{Dist: d, Min: min, Max: max, hi: d.CDF(max)}
+	if _, isDiscrete := d.(discrete); isDiscrete {
+		t.lo = d.CDF(math.Ceil(min) - 1)
+	} else {
+		t.lo = d.CDF(min)
+	}
+	if t.hi-t.lo < 1e-12 {
+		return nil, fmt.Errorf("truncation to [%v, %v] leaves no values", min, max)
+	}
+	return t, nil
+}
+
+func (t *Truncated) Rand(rnd *rand.Rand) float64 {
+	return t.Quantile(rnd.Float64())
+}
+
+func (t *Truncated) Mean() float64 {
+	return meanByQuantile(t)
+}
+
+func (t *Truncated) CDF(x float64) float64 {
+	switch {
+	case x < t.Min:
+		return 0
+	case x >= t.Max:
+		return 1
+	}
+	return (t.Dist.CDF(x) - t.lo) / (t.hi - t.lo)
+}
+
+func (t *Truncated) Quantile(p float64) float64 {
+	x := t.Dist.Quantile(t.lo + p*(t.hi-t.lo))
+	return math.Max(t.Min, math.Min(t.Max, x))
+}
+
+// meanByQuantile returns the mean of d by numerical integration of its quantile function
+func meanByQuantile(d Distribution) float64 {
+	const n = 10000
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += d.Quantile((float64(i) + 0.5) / n)
+	}
+	return sum / n
+}
+
+// checkPositive returns an error if a parameter is not > 0
+func checkPositive(name string, v float64) error {
+	if !(v > 0) || math.IsInf(v, 1) {
+		return fmt.Errorf("%s must be > 0, got %v", name, v)
+	}
+	return nil
+}
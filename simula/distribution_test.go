@@ -0,0 +1,153 @@
+package simula
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/drgo/sim/stattest"
+)
+
+func TestDistributions(t *testing.T) {
+	const n = 100000
+	tests := []struct {
+		spec string
+		mean float64
+		min  float64
+		max  float64
+	}{
+		{`{"dist":"uniform","min":2,"max":4}`, 3, 2, 4},
+		{`{"dist":"normal","mu":5,"sigma":2}`, 5, math.Inf(-1), math.Inf(1)},
+		{`{"dist":"normal","mean":5,"sd":2,"min":0}`, 5.0353, 0, math.Inf(1)},
+		{`{"dist":"truncated_normal","mu":0,"sigma":1,"min":-1,"max":1}`, 0, -1, 1},
+		{`{"dist":"lognormal","mean":7,"sd":4}`, 7, 0, math.Inf(1)},
+		{`{"dist":"gamma","shape":2,"scale":3}`, 6, 0, math.Inf(1)},
+		{`{"dist":"gamma","shape":0.5,"rate":2}`, 0.25, 0, math.Inf(1)},
+		{`{"dist":"gamma","mean":7,"sd":4,"max":10}`, 5.4466, 0, 10},
+		{`{"dist":"exponential","mean":3}`, 3, 0, math.Inf(1)},
+		{`{"dist":"weibull","mean":7,"sd":4}`, 7, 0, math.Inf(1)},
+		{`{"dist":"gompertz","shape":0.1,"scale":0.5}`, 4.0293, 0, math.Inf(1)},
+		{`{"dist":"beta","alpha":2,"beta":5}`, 2.0 / 7, 0, 1},
+		{`{"dist":"poisson","lambda":3}`, 3, 0, math.Inf(1)},
+		{`{"dist":"poisson","mean":40}`, 40, 0, math.Inf(1)},
+		{`{"dist":"poisson","lambda":3,"min":1,"max":5}`, 2.8233, 1, 5},
+		{`{"dist":"negative_binomial","r":2,"p":0.25}`, 6, 0, math.Inf(1)},
+		{`{"dist":"negative_binomial","mean":20,"r":5}`, 20, 0, math.Inf(1)},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			var d Dist
+			if err := json.Unmarshal([]byte(tt.spec), &d); err != nil {
+				t.Fatal(err)
+			}
+			if got := d.Mean(); math.Abs(got-tt.mean) > 1e-3*math.Max(1, tt.mean) {
+				t.Errorf("Mean() = %v, want %v", got, tt.mean)
+			}
+			_, isDiscrete := d.Distribution.(discrete)
+			if tr, ok := d.Distribution.(*Truncated); ok {
+				_, isDiscrete = tr.Dist.(discrete)
+			}
+			sample := make([]float64, n)
+			for i := range sample {
+				x := d.Rand(rnd)
+				if x < tt.min || x > tt.max {
+					t.Fatalf("Rand() = %v, outside [%v, %v]", x, tt.min, tt.max)
+				}
+				sample[i] = x
+			}
+			if isDiscrete {
+				stattest.CheckDiscrete(t, sample, d.CDF, stattest.Alpha)
+			} else {
+				stattest.CheckSample(t, sample, d.CDF, stattest.Alpha)
+			}
+			for _, p := range []float64{0.1, 0.5, 0.9} {
+				q := d.Quantile(p)
+				if got := d.CDF(q); got < p-1e-9 {
+					t.Errorf("CDF(Quantile(%v)) = %v", p, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDistErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{`{"dist":"gama","shape":2,"scale":3}`, `unknown distribution "gama"`},
+		{`{"shape":2,"scale":3}`, "dist field"},
+		{`{"dist":"gamma","shape":2}`, "parameters must be shape and scale, or shape and rate, or mean and sd"},
+		{`{"dist":"gamma","shape":2,"scale":3,"mean":6}`, "parameters must be"},
+		{`{"dist":"gamma","shape":"2","scale":3}`, "shape must be a number"},
+		{`{"dist":"gamma","shape":-1,"scale":3}`, "shape must be > 0"},
+		{`{"dist":"truncated_normal","mu":0,"sigma":1}`, "min or max is required"},
+		{`{"dist":"normal","mu":0,"sigma":1,"min":3,"max":2}`, "must be <= max"},
+		{`{"dist":"poisson","lambda":1,"min":1.2,"max":1.8}`, "leaves no values"},
+		{`{"dist":"negative_binomial","r":2,"p":1.5}`, "p must be > 0 and <= 1"},
+		{`[1,2]`, "must be an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			var d Dist
+			err := json.Unmarshal([]byte(tt.spec), &d)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistMarshal(t *testing.T) {
+	var d Dist
+	if err := json.Unmarshal([]byte(`{"dist":"gamma","shape":2,"scale":3}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"dist":"gamma","scale":3,"shape":2}`; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
+
+// BenchmarkDistributions draws from each distribution with random valid parameters
+func BenchmarkDistributions(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, name := range Names() {
+		params := map[string]float64{}
+		switch name {
+		case "uniform":
+			params["min"], params["max"] = rnd.Float64(), 1+rnd.Float64()
+		case "beta":
+			params["alpha"], params["beta"] = 0.5+5*rnd.Float64(), 0.5+5*rnd.Float64()
+		case "poisson":
+			params["lambda"] = 50 * rnd.Float64()
+		case "negative_binomial":
+			params["r"], params["p"] = 1+10*rnd.Float64(), 0.1+0.8*rnd.Float64()
+		case "truncated_normal":
+			params["mu"], params["sigma"], params["min"] = 10*rnd.Float64(), 1+rnd.Float64(), 0
+		case "exponential":
+			params["rate"] = 0.1 + rnd.Float64()
+		default:
+			params["mean"], params["sd"] = 1+10*rnd.Float64(), 1+rnd.Float64()
+			if name == "gompertz" {
+				params = map[string]float64{"shape": 0.01 + rnd.Float64(), "scale": 0.01 + rnd.Float64()}
+			}
+		}
+		d, err := New(name, params)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%s %v", name, params), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				d.Rand(rnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package simula
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mathext"
+)
+
+// Gamma is the gamma distribution with shape Shape and scale Scale
+type Gamma struct {
+	Shape, Scale float64
+}
+
+func NewGamma(shape, scale float64) (*Gamma, error) {
+	if err := checkPositive("shape", shape); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("scale", scale); err != nil {
+		return nil, err
+	}
+	return &Gamma{Shape: shape, Scale: scale}, nil
+}
+
+// GammaFromMoments returns the gamma distribution with the desired mean and sd
+func GammaFromMoments(mean, sd float64) (*Gamma, error) {
+	if err := checkPositive("mean", mean); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("sd", sd); err != nil {
+		return nil, err
+	}
+	return NewGamma(mean*mean/(sd*sd), sd*sd/mean)
+}
+
+func (g *Gamma) Rand(rnd *rand.Rand) float64 {
+	return gammaRand(rnd, g.Shape) * g.Scale
+}
+
+func (g *Gamma) Mean() float64 { return g.Shape * g.Scale }
+
+func (g *Gamma) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return mathext.GammaIncReg(g.Shape, x/g.Scale)
+}
+
+func (g *Gamma) Quantile(p float64) float64 {
+	return mathext.GammaIncRegInv(g.Shape, p) * g.Scale
+}
+
+// gammaRand returns a draw from a gamma dis with scale 1 using Marsaglia and Tsang's method
+func gammaRand(rnd *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return gammaRand(rnd, shape+1) * math.Pow(rnd.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rnd.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rnd.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// Exponential is the exponential distribution with rate Rate
+type Exponential struct {
+	Rate float64
+}
+
+func NewExponential(rate float64) (*Exponential, error) {
+	if err := checkPositive("rate", rate); err != nil {
+		return nil, err
+	}
+	return &Exponential{Rate: rate}, nil
+}
+
+func (e *Exponential) Rand(rnd *rand.Rand) float64 {
+	return rnd.ExpFloat64() / e.Rate
+}
+
+func (e *Exponential) Mean() float64 { return 1 / e.Rate }
+
+func (e *Exponential) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return -math.Expm1(-e.Rate * x)
+}
+
+func (e *Exponential) Quantile(p float64) float64 {
+	return -math.Log1p(-p) / e.Rate
+}
+
+// Beta is the beta distribution with shape parameters Alpha and Beta
+type Beta struct {
+	Alpha, Beta float64
+}
+
+func NewBeta(alpha, beta float64) (*Beta, error) {
+	if err := checkPositive("alpha", alpha); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("beta", beta); err != nil {
+		return nil, err
+	}
+	return &Beta{Alpha: alpha, Beta: beta}, nil
+}
+
+func (b *Beta) Rand(rnd *rand.Rand) float64 {
+	x := gammaRand(rnd, b.Alpha)
+	return x / (x + gammaRand(rnd, b.Beta))
+}
+
+func (b *Beta) Mean() float64 { return b.Alpha / (b.Alpha + b.Beta) }
+
+func (b *Beta) CDF(x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	return mathext.RegIncBeta(b.Alpha, b.Beta, x)
+}
+
+func (b *Beta) Quantile(p float64) float64 {
+	return mathext.InvRegIncBeta(b.Alpha, b.Beta, p)
+}
@@ -0,0 +1,84 @@
+package simula
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mathext"
+)
+
+// Normal is the normal distribution with mean Mu and standard deviation Sigma
+type Normal struct {
+	Mu, Sigma float64
+}
+
+// NewNormal returns a normal distribution; sigma may be 0
+func NewNormal(mu, sigma float64) (*Normal, error) {
+	if sigma != 0 {
+		if err := checkPositive("sigma", sigma); err != nil {
+			return nil, err
+		}
+	}
+	return &Normal{Mu: mu, Sigma: sigma}, nil
+}
+
+func (n *Normal) Rand(rnd *rand.Rand) float64 {
+	return rnd.NormFloat64()*n.Sigma + n.Mu
+}
+
+func (n *Normal) Mean() float64 { return n.Mu }
+
+func (n *Normal) CDF(x float64) float64 {
+	if n.Sigma == 0 {
+		if x < n.Mu {
+			return 0
+		}
+		return 1
+	}
+	return 0.5 * math.Erfc(-(x-n.Mu)/(n.Sigma*math.Sqrt2))
+}
+
+func (n *Normal) Quantile(p float64) float64 {
+	return n.Mu + n.Sigma*mathext.NormalQuantile(p)
+}
+
+// LogNormal is the distribution of exp(X) where X is normal with mean Mu and standard deviation Sigma
+type LogNormal struct {
+	Mu, Sigma float64
+}
+
+func NewLogNormal(mu, sigma float64) (*LogNormal, error) {
+	if err := checkPositive("sigma", sigma); err != nil {
+		return nil, err
+	}
+	return &LogNormal{Mu: mu, Sigma: sigma}, nil
+}
+
+// LogNormalFromMoments returns the lognormal distribution with the desired mean and sd
+func LogNormalFromMoments(mean, sd float64) (*LogNormal, error) {
+	if err := checkPositive("mean", mean); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("sd", sd); err != nil {
+		return nil, err
+	}
+	sigma2 := math.Log(1 + sd*sd/(mean*mean))
+	return NewLogNormal(math.Log(mean)-sigma2/2, math.Sqrt(sigma2))
+}
+
+func (l *LogNormal) Rand(rnd *rand.Rand) float64 {
+	return math.Exp(rnd.NormFloat64()*l.Sigma + l.Mu)
+}
+
+func (l *LogNormal) Mean() float64 { return math.Exp(l.Mu + l.Sigma*l.Sigma/2) }
+
+func (l *LogNormal) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return 0.5 * math.Erfc(-(math.Log(x)-l.Mu)/(l.Sigma*math.Sqrt2))
+}
+
+func (l *LogNormal) Quantile(p float64) float64 {
+	return math.Exp(l.Mu + l.Sigma*mathext.NormalQuantile(p))
+}
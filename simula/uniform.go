@@ -1,8 +1,38 @@
 package simula
 
-import "math/rand"
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
 
 // Returns an int >= min, < max
-func UniformRangeRand(min, max int) int {
-	return min + rand.Intn(max-min)
+func UniformRangeRand(rnd *rand.Rand, min, max int) int {
+	return min + rnd.Intn(max-min)
+}
+
+// Uniform is the continuous uniform distribution on [Min, Max)
+type Uniform struct {
+	Min, Max float64
+}
+
+func NewUniform(min, max float64) (*Uniform, error) {
+	if math.IsInf(min, 0) || math.IsInf(max, 0) || !(min < max) {
+		return nil, fmt.Errorf("min (%v) must be < max (%v) and both must be finite", min, max)
+	}
+	return &Uniform{Min: min, Max: max}, nil
+}
+
+func (u *Uniform) Rand(rnd *rand.Rand) float64 {
+	return u.Min + (u.Max-u.Min)*rnd.Float64()
+}
+
+func (u *Uniform) Mean() float64 { return (u.Min + u.Max) / 2 }
+
+func (u *Uniform) CDF(x float64) float64 {
+	return math.Max(0, math.Min(1, (x-u.Min)/(u.Max-u.Min)))
+}
+
+func (u *Uniform) Quantile(p float64) float64 {
+	return u.Min + (u.Max-u.Min)*p
 }
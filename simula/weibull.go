@@ -5,38 +5,120 @@ import (
 	"math/rand"
 )
 
-//TODO: use new source to avoid locking
+// Weibull is the Weibull distribution with shape Shape (k) and scale Scale (lambda).
+// A shape of 1 is the exponential distribution and a shape of 2 the Rayleigh distribution.
+type Weibull struct {
+	Shape, Scale float64
+}
+
+func NewWeibull(shape, scale float64) (*Weibull, error) {
+	if err := checkPositive("shape", shape); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("scale", scale); err != nil {
+		return nil, err
+	}
+	return &Weibull{Shape: shape, Scale: scale}, nil
+}
+
+// WeibullFromMoments returns the Weibull distribution with the desired mean and sd
+func WeibullFromMoments(mean, sd float64) (*Weibull, error) {
+	if err := checkPositive("mean", mean); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("sd", sd); err != nil {
+		return nil, err
+	}
+	k := weibullShape(sd / mean)
+	return NewWeibull(k, mean/math.Gamma(1+1/k))
+}
 
-func weibullRand(k, lambda float64) float64 {
-	return lambda * math.Pow(-math.Log(1-rand.Float64()), 1/k)
+// weibullShape returns the shape of the Weibull dis with the desired coefficient of variation
+func weibullShape(cv float64) float64 {
+	cvOf := func(k float64) float64 {
+		g1 := math.Gamma(1 + 1/k)
+		return math.Sqrt(math.Gamma(1+2/k)/(g1*g1) - 1)
+	}
+	// cv decreases with k
+	lo, hi := 0.1, 100.0
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if cvOf(mid) > cv {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
 }
 
-// WeibullVector returns a random sample drawn from the distribution.
-// k: Shape parameter of the distribution. A value of 1 represents
-// the exponential distribution. A value of 2 represents the
-// Rayleigh distribution. Valid range is (0,+∞).
-// lambda Scale parameter of the distribution. Valid range is (0,+∞).
-func WeibullVector(k, lambda float64, size int64) []float64 {
+func (w *Weibull) Rand(rnd *rand.Rand) float64 {
+	return w.Quantile(rnd.Float64())
+}
+
+func (w *Weibull) Mean() float64 { return w.Scale * math.Gamma(1+1/w.Shape) }
+
+func (w *Weibull) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return -math.Expm1(-math.Pow(x/w.Scale, w.Shape))
+}
+
+func (w *Weibull) Quantile(p float64) float64 {
+	return w.Scale * math.Pow(-math.Log1p(-p), 1/w.Shape)
+}
+
+// WeibullVector returns a random sample drawn from the Weibull distribution with shape k and scale lambda.
+func WeibullVector(rnd *rand.Rand, k, lambda float64, size int64) []float64 {
+	w := &Weibull{Shape: k, Scale: lambda}
 	data := make([]float64, size)
 	for i := int64(0); i < size; i++ {
-		data[i] = weibullRand(k, lambda)
+		data[i] = w.Rand(rnd)
 	}
 	return data
 }
 
-
-// WeibullVectorInt returns a random sample drawn from the distribution.
-// k: Shape parameter of the distribution. A value of 1 represents
-// the exponential distribution. A value of 2 represents the
-// Rayleigh distribution. Valid range is (0,+∞).
-// lambda Scale parameter of the distribution. Valid range is (0,+∞).
-func WeibullVectorInt(k, lambda float64, size int64) []int {
-	func weibullRandInt() int {
-		return lambda * math.Pow(-math.Log(1-rand.Int()), 1/k)
-	}
+// WeibullVectorInt returns a random sample drawn from the Weibull distribution with shape k and scale lambda,
+// rounded to the nearest integer.
+func WeibullVectorInt(rnd *rand.Rand, k, lambda float64, size int64) []int {
+	w := &Weibull{Shape: k, Scale: lambda}
 	data := make([]int, size)
 	for i := int64(0); i < size; i++ {
-		data[i] = weibullRandInt(k, lambda)
+		data[i] = int(math.Round(w.Rand(rnd)))
 	}
 	return data
 }
+
+// Gompertz is the Gompertz distribution with shape Shape (eta) and scale Scale (b): the hazard
+// at x is Shape*Scale*exp(Scale*x). It describes adult ages at death.
+type Gompertz struct {
+	Shape, Scale float64
+}
+
+func NewGompertz(shape, scale float64) (*Gompertz, error) {
+	if err := checkPositive("shape", shape); err != nil {
+		return nil, err
+	}
+	if err := checkPositive("scale", scale); err != nil {
+		return nil, err
+	}
+	return &Gompertz{Shape: shape, Scale: scale}, nil
+}
+
+func (g *Gompertz) Rand(rnd *rand.Rand) float64 {
+	return math.Log1p(rnd.ExpFloat64()/g.Shape) / g.Scale
+}
+
+func (g *Gompertz) Mean() float64 { return meanByQuantile(g) }
+
+func (g *Gompertz) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return -math.Expm1(-g.Shape * math.Expm1(g.Scale*x))
+}
+
+func (g *Gompertz) Quantile(p float64) float64 {
+	return math.Log1p(-math.Log1p(-p)/g.Shape) / g.Scale
+}
@@ -0,0 +1,154 @@
+// Package stattest provides goodness of fit tests for testing random number generators.
+//
+// A test of a correct generator fails with probability alpha, its false-failure rate, so tests
+// should use a small alpha such as Alpha and a fixed seed. A test that fails after a change of
+// seed or generator is unlikely to be a false failure.
+package stattest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/mathext"
+)
+
+// Alpha is the false-failure rate of each check of the tests of this module
+const Alpha = 1e-4
+
+// minExpected is the smallest expected count of a chi-square category; smaller categories are
+// pooled with their neighbours
+const minExpected = 5
+
+// ChiSquare returns Pearson's chi-square statistic and its p-value for observed counts of
+// categories of probabilities probs. probs are normalised to sum to 1. Categories with small
+// expected counts are pooled. A category of probability 0 with a count returns a p-value of 0.
+func ChiSquare(observed []int, probs []float64) (stat, p float64, err error) {
+	if len(observed) != len(probs) || len(probs) == 0 {
+		return 0, 0, fmt.Errorf("got %d counts for %d probabilities", len(observed), len(probs))
+	}
+	n, sum := 0, 0.0
+	for i, prob := range probs {
+		if prob < 0 || math.IsNaN(prob) || math.IsInf(prob, 0) {
+			return 0, 0, fmt.Errorf("invalid probability %v at position %d", prob, i)
+		}
+		if prob == 0 && observed[i] > 0 {
+			return math.Inf(1), 0, nil
+		}
+		n += observed[i]
+		sum += prob
+	}
+	if n == 0 || sum == 0 {
+		return 0, 0, fmt.Errorf("no counts or probabilities")
+	}
+	// pool consecutive categories until their expected count is large enough; a small remainder
+	// is pooled with the last category
+	var obs, exp []float64
+	o, e := 0.0, 0.0
+	for i, prob := range probs {
+		o += float64(observed[i])
+		e += prob / sum * float64(n)
+		if e >= minExpected {
+			obs, exp = append(obs, o), append(exp, e)
+			o, e = 0, 0
+		}
+	}
+	if len(exp) == 0 {
+		return 0, 1, nil
+	}
+	obs[len(obs)-1] += o
+	exp[len(exp)-1] += e
+	for i := range exp {
+		stat += (obs[i] - exp[i]) * (obs[i] - exp[i]) / exp[i]
+	}
+	if len(exp) < 2 {
+		return stat, 1, nil
+	}
+	return stat, mathext.GammaIncRegComp(float64(len(exp)-1)/2, stat/2), nil
+}
+
+// KS returns the Kolmogorov-Smirnov statistic and its asymptotic p-value for a sample of a
+// continuous distribution of cumulative distribution function cdf. sample is sorted in place.
+func KS(sample []float64, cdf func(x float64) float64) (d, p float64) {
+	sort.Float64s(sample)
+	n := float64(len(sample))
+	for i, x := range sample {
+		f := cdf(x)
+		d = math.Max(d, math.Max(f-float64(i)/n, float64(i+1)/n-f))
+	}
+	sqrtN := math.Sqrt(n)
+	return d, kolmogorov((sqrtN + 0.12 + 0.11/sqrtN) * d)
+}
+
+// kolmogorov returns the probability that the Kolmogorov distribution exceeds lambda
+func kolmogorov(lambda float64) float64 {
+	if lambda < 0.2 {
+		return 1
+	}
+	sum, sign := 0.0, 1.0
+	for j := 1.0; j <= 100; j++ {
+		term := sign * math.Exp(-2*j*j*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, 2*sum))
+}
+
+// CheckCounts fails t if observed counts are unlikely for categories of probabilities probs at
+// significance level alpha
+func CheckCounts(t testing.TB, observed []int, probs []float64, alpha float64) {
+	t.Helper()
+	stat, p, err := ChiSquare(observed, probs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p < alpha {
+		t.Errorf("counts %v do not fit probabilities %v: chi-square %.2f, p-value %.2g < %g", observed, probs, stat, p, alpha)
+	}
+}
+
+// CheckSample fails t if a sample of a continuous distribution is unlikely to have cumulative
+// distribution function cdf at significance level alpha
+func CheckSample(t testing.TB, sample []float64, cdf func(x float64) float64, alpha float64) {
+	t.Helper()
+	if d, p := KS(sample, cdf); p < alpha {
+		t.Errorf("sample of %d values does not fit the distribution: KS statistic %.4f, p-value %.2g < %g", len(sample), d, p, alpha)
+	}
+}
+
+// CheckDiscrete fails t if a sample of a distribution of integers is unlikely to have cumulative
+// distribution function cdf at significance level alpha. Values below the smallest value of
+// the sample and above the largest are pooled with them.
+func CheckDiscrete(t testing.TB, sample []float64, cdf func(x float64) float64, alpha float64) {
+	t.Helper()
+	if len(sample) == 0 {
+		t.Fatal("empty sample")
+	}
+	min, max := sample[0], sample[0]
+	for _, x := range sample {
+		if x != math.Floor(x) {
+			t.Fatalf("value %v is not an integer", x)
+		}
+		min, max = math.Min(min, x), math.Max(max, x)
+	}
+	counts := make([]int, int(max-min)+1)
+	for _, x := range sample {
+		counts[int(x-min)]++
+	}
+	probs := make([]float64, len(counts))
+	below := 0.0
+	for i := range probs {
+		k := min + float64(i)
+		f := cdf(k)
+		if i == len(probs)-1 {
+			f = 1
+		}
+		probs[i] = f - below
+		below = f
+	}
+	CheckCounts(t, counts, probs, alpha)
+}
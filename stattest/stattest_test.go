@@ -0,0 +1,93 @@
+package stattest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestFalseFailureRate checks that p-values of samples of the tested distribution are uniform, so
+// that a test at significance level alpha fails a correct generator with probability alpha
+func TestFalseFailureRate(t *testing.T) {
+	const (
+		trials = 2000
+		alpha  = 0.1
+	)
+	rnd := rand.New(rand.NewSource(1))
+	probs := []float64{0.5, 0.25, 0.125, 0.0625, 0.0625}
+	uniform := func(x float64) float64 { return math.Max(0, math.Min(1, x)) }
+	var chiFailures, ksFailures int
+	for i := 0; i < trials; i++ {
+		counts := make([]int, len(probs))
+		for j := 0; j < 200; j++ {
+			u, k := rnd.Float64(), 0
+			for ; k < len(probs)-1 && u >= probs[k]; k++ {
+				u -= probs[k]
+			}
+			counts[k]++
+		}
+		if _, p, err := ChiSquare(counts, probs); err != nil {
+			t.Fatal(err)
+		} else if p < alpha {
+			chiFailures++
+		}
+		sample := make([]float64, 200)
+		for j := range sample {
+			sample[j] = rnd.Float64()
+		}
+		if _, p := KS(sample, uniform); p < alpha {
+			ksFailures++
+		}
+	}
+	// the number of failures is binomial(trials, alpha)
+	sd := math.Sqrt(trials * alpha * (1 - alpha))
+	for name, failures := range map[string]int{"chi-square": chiFailures, "KS": ksFailures} {
+		if math.Abs(float64(failures)-trials*alpha) > 5*sd {
+			t.Errorf("%s: %d failures in %d trials, want %.0f ± %.0f", name, failures, trials, trials*alpha, 5*sd)
+		}
+	}
+}
+
+func TestChiSquare(t *testing.T) {
+	tests := []struct {
+		name     string
+		observed []int
+		probs    []float64
+		fit      bool
+	}{
+		{"fair", []int{1010, 990, 1003, 997}, []float64{1, 1, 1, 1}, true},
+		{"biased", []int{1150, 850, 1000, 1000}, []float64{1, 1, 1, 1}, false},
+		{"impossible", []int{500, 500, 1}, []float64{0.5, 0.5, 0}, false},
+		{"pooled tail", []int{50, 47, 2, 1, 0}, []float64{0.5, 0.47, 0.02, 0.01, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p, err := ChiSquare(tt.observed, tt.probs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fit := p >= Alpha; fit != tt.fit {
+				t.Errorf("p-value %v, want fit %v", p, tt.fit)
+			}
+		})
+	}
+	if _, _, err := ChiSquare([]int{1}, []float64{0.5, 0.5}); err == nil {
+		t.Error("mismatched counts and probabilities should be rejected")
+	}
+}
+
+func TestKS(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	sample := make([]float64, 10000)
+	for i := range sample {
+		sample[i] = rnd.NormFloat64()
+	}
+	normal := func(x float64) float64 { return 0.5 * math.Erfc(-x/math.Sqrt2) }
+	if _, p := KS(sample, normal); p < Alpha {
+		t.Errorf("normal sample: p-value %v", p)
+	}
+	shifted := func(x float64) float64 { return normal(x - 0.1) }
+	if _, p := KS(sample, shifted); p >= Alpha {
+		t.Errorf("shifted normal: p-value %v, want < %v", p, Alpha)
+	}
+}
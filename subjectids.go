@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/drgo/sim/rng"
+)
+
+// SubjectIDs holds config for the subject ids written to the csv files
+type SubjectIDs struct {
+	Scheme    string           `json:"scheme"`    // sequential (default), random, luhn or hashed
+	FirstID   int64            `json:"first_id"`  // first sequential id
+	Digits    int              `json:"digits"`    // length of random, luhn and hashed ids
+	Key       string           `json:"key"`       // key of hashed ids
+	Crosswalk bool             `json:"crosswalk"` // writes crosswalk.csv
+	seed      uint64           // of random and luhn ids
+	size      uint64           // number of random ids, or of luhn ids without their check digit
+	halfBits  uint             // half the bits of the numbers permuted to draw random ids
+	hashed    map[string]int64 // index of the person by hashed id given, to detect collisions
+}
+
+// minHashedDigits is the minimum length of hashed ids: the chance that two of a million hashed ids
+// of 16 hexadecimal digits collide is about 3 in 100 million
+const minHashedDigits = 16
+
+// subjectIDSchemes are the valid schemes of subject ids
+var subjectIDSchemes = []string{"sequential", "random", "luhn", "hashed"}
+
+// validate checks the subject ids entry and sets its defaults; random and luhn ids are drawn using seed
+func (s *SubjectIDs) validate(seed int) error {
+	if s.Scheme == "" {
+		s.Scheme = "sequential"
+	}
+	if s.FirstID == 0 {
+		s.FirstID = firstID
+	}
+	if s.FirstID < 0 {
+		return fmt.Errorf("subject_ids first_id must not be negative, got %d", s.FirstID)
+	}
+	switch s.Scheme {
+	case "sequential":
+		return nil
+	case "random", "luhn":
+		if s.Digits == 0 {
+			s.Digits = 9
+		}
+		if s.Digits < 2 || s.Digits > 18 {
+			return fmt.Errorf("subject_ids digits must be between 2 and 18 for %s ids, got %d", s.Scheme, s.Digits)
+		}
+		digits := s.Digits
+		if s.Scheme == "luhn" {
+			digits-- // the check digit
+		}
+		s.size = 9 * pow10(digits-1) // no leading zero
+		for s.halfBits = 1; s.size > 1<<(2*s.halfBits); s.halfBits++ {
+		}
+		s.seed = uint64(seed)
+	case "hashed":
+		if s.Digits == 0 {
+			s.Digits = 16
+		}
+		if s.Digits < minHashedDigits || s.Digits > 2*sha256.Size {
+			return fmt.Errorf("subject_ids digits must be between %d and %d for hashed ids, got %d", minHashedDigits, 2*sha256.Size, s.Digits)
+		}
+		if s.Key == "" {
+			s.Key = strconv.Itoa(seed)
+		}
+	default:
+		return fmt.Errorf("subject_ids scheme must be one of %s, got %q", strings.Join(subjectIDSchemes, ", "), s.Scheme)
+	}
+	return nil
+}
+
+// sequentialID returns the sequential id of the person with the given index over all units
+func (s *SubjectIDs) sequentialID(index int64) string {
+	if s == nil {
+		return strconv.FormatInt(firstID+index, 10)
+	}
+	return strconv.FormatInt(s.FirstID+index, 10)
+}
+
+// id returns the subject id of the person with the given index over all units. A nil SubjectIDs
+// gives sequential ids from 1000001. Hashed ids are checked against the ids given before, so id
+// must not be called concurrently.
+func (s *SubjectIDs) id(index int64) (string, error) {
+	if s == nil {
+		return s.sequentialID(index), nil
+	}
+	switch s.Scheme {
+	case "random", "luhn":
+		if uint64(index) >= s.size {
+			return "", fmt.Errorf("subject_ids: %d digits are too few for %s ids of more than %d people", s.Digits, s.Scheme, s.size)
+		}
+		id := strconv.FormatUint(s.size/9+s.permute(uint64(index)), 10)
+		if s.Scheme == "luhn" {
+			id += string('0' + luhn(id))
+		}
+		return id, nil
+	case "hashed":
+		mac := hmac.New(sha256.New, []byte(s.Key))
+		mac.Write([]byte(s.sequentialID(index)))
+		id := hex.EncodeToString(mac.Sum(nil))[:s.Digits]
+		if s.hashed == nil {
+			s.hashed = make(map[string]int64)
+		}
+		if other, ok := s.hashed[id]; ok && other != index {
+			return "", fmt.Errorf("subject_ids: the hashed ids of people %s and %s are both %s; use more digits or another key", s.sequentialID(other), s.sequentialID(index), id)
+		}
+		s.hashed[id] = index
+		return id, nil
+	}
+	return s.sequentialID(index), nil
+}
+
+// permute returns the image of x, between 0 and size-1, by a random permutation of these numbers, so
+// random ids are unique without keeping the ids given. The permutation is a Feistel network over
+// the numbers of 2*halfBits bits, applied again to values out of range (cycle walking).
+func (s *SubjectIDs) permute(x uint64) uint64 {
+	mask := uint64(1)<<s.halfBits - 1
+	for {
+		left, right := x>>s.halfBits, x&mask
+		for round := uint64(0); round < 4; round++ {
+			left, right = right, left^rng.Derive(s.seed, streamSubjectIDs, round, right).Uint64()&mask
+		}
+		if x = left<<s.halfBits | right; x < s.size {
+			return x
+		}
+	}
+}
+
+// luhn returns the Luhn check digit of a number formatted in decimal
+func luhn(number string) byte {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if (len(number)-i)%2 == 1 { // every other digit from the rightmost is doubled
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte((10 - sum%10) % 10)
+}
+
+// pow10 returns 10 to the power n
+func pow10(n int) uint64 {
+	p := uint64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   byte
+	}{
+		{"7992739871", 3},
+		{"0", 0},
+		{"1", 8},
+		{"12345", 5},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.number); got != tt.want {
+			t.Errorf("luhn(%s) = %d, want %d", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestSubjectIDs(t *testing.T) {
+	tests := []struct {
+		ids   SubjectIDs
+		n     int
+		check func(id string) bool
+	}{
+		{SubjectIDs{}, 10, func(id string) bool { return len(id) == 7 }},
+		{SubjectIDs{Scheme: "random", Digits: 3}, 900, func(id string) bool { return len(id) == 3 && id[0] != '0' }},
+		{SubjectIDs{Scheme: "random"}, 1000, func(id string) bool { return len(id) == 9 }},
+		{SubjectIDs{Scheme: "luhn", Digits: 4}, 900, func(id string) bool {
+			return len(id) == 4 && luhn(id[:3]) == id[3]-'0'
+		}},
+		{SubjectIDs{Scheme: "hashed", Key: "secret"}, 1000, func(id string) bool { return len(id) == 16 }},
+	}
+	for _, tt := range tests {
+		if err := tt.ids.validate(1); err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for i := 0; i < tt.n; i++ {
+			id, err := tt.ids.id(int64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[id] || !tt.check(id) {
+				t.Errorf("%s: id %d = %s is a duplicate or badly formatted", tt.ids.Scheme, i, id)
+			}
+			seen[id] = true
+		}
+	}
+	ids := SubjectIDs{Scheme: "random", Digits: 3}
+	ids.validate(1)
+	if _, err := ids.id(900); err == nil {
+		t.Errorf("random ids of 3 digits should run out after 900 people")
+	}
+	if got, _ := (&SubjectIDs{FirstID: 5}).id(2); got != "7" {
+		t.Errorf("sequential id 2 from 5 = %s, want 7", got)
+	}
+	hashed := SubjectIDs{Scheme: "hashed"}
+	hashed.validate(1)
+	hashed.Digits = 2 // too few to be valid: 256 ids
+	for i := 0; i <= 256; i++ {
+		if _, err := hashed.id(int64(i)); err != nil {
+			break
+		} else if i == 256 {
+			t.Errorf("257 hashed ids of 2 digits should collide")
+		}
+	}
+	if _, err := hashed.id(0); err != nil {
+		t.Errorf("the id of a person given again is not a collision: %s", err)
+	}
+	for _, invalid := range []SubjectIDs{{Scheme: "scrambled"}, {Scheme: "luhn", Digits: 19}, {Scheme: "hashed", Digits: 4}, {Scheme: "hashed", Digits: 65}, {FirstID: -1}} {
+		if err := invalid.validate(1); err == nil {
+			t.Errorf("%+v should be invalid", invalid)
+		}
+	}
+}
+
+func TestCrosswalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := readConfigDocument("./config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.N, config.quiet = 50, true
+	config.SubjectIDs = &SubjectIDs{Scheme: "luhn", Crosswalk: true}
+	config.dispatcher = NewDispatcher(bufferSize, config)
+	if config, err = ProcessConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if err = run(config, dir); err != nil {
+		t.Fatal(err)
+	}
+	people := make(map[string]bool)
+	err = readCSVFile(filepath.Join(dir, "person.csv"), func(rec map[string]string) error {
+		people[rec["subject_id"]] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequential := firstID
+	err = readCSVFile(filepath.Join(dir, "crosswalk.csv"), func(rec map[string]string) error {
+		if id := rec["subject_id"]; !people[id] {
+			t.Errorf("crosswalk subject_id %s is not in person.csv", id)
+		}
+		if rec["sequential_id"] != strconv.Itoa(sequential) {
+			t.Errorf("sequential_id = %s, want %d", rec["sequential_id"], sequential)
+		}
+		delete(people, rec["subject_id"])
+		sequential++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) > 0 {
+		t.Errorf("%d people are not in the crosswalk", len(people))
+	}
+	// rows are in the order of the sequential ids, then service date, not of the luhn ids
+	sequentialOf := make(map[string]int)
+	err = readCSVFile(filepath.Join(dir, "crosswalk.csv"), func(rec map[string]string) error {
+		sequentialOf[rec["subject_id"]], _ = strconv.Atoi(rec["sequential_id"])
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, category := range []string{"person", "hosp", "clinic", "rx"} {
+		var last struct {
+			sequential int
+			subject    string
+			date       string
+		}
+		unsorted := false
+		err = readCSVFile(filepath.Join(dir, category+".csv"), func(rec map[string]string) error {
+			sequential := sequentialOf[rec["subject_id"]]
+			if sequential < last.sequential || sequential == last.sequential && rec["service_date"] < last.date {
+				t.Errorf("%s: person %d on %s follows person %d on %s", category, sequential, rec["service_date"], last.sequential, last.date)
+			}
+			unsorted = unsorted || rec["subject_id"] < last.subject
+			last.sequential, last.subject, last.date = sequential, rec["subject_id"], rec["service_date"]
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if category == "person" && !unsorted {
+			t.Errorf("person.csv is sorted by luhn ids, which should not reveal the order of the people")
+		}
+	}
+}
+
+func TestRunOutOfIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := readConfigDocument("./config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.N, config.quiet = 90, true
+	config.SubjectIDs = &SubjectIDs{Scheme: "random", Digits: 2}
+	config.dispatcher = NewDispatcher(bufferSize, config)
+	if config, err = ProcessConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	// more people than ids, as when a demography has more newborns than expected
+	config.N = 100
+	if err = run(config, dir); err == nil || !strings.Contains(err.Error(), "too few") {
+		t.Errorf("got error %v, want subject ids to run out", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "manifest.json")); err == nil {
+		t.Errorf("a manifest was written for incomplete data")
+	}
+}